@@ -2,7 +2,11 @@ package consts
 
 import "errors"
 
-const ConfigName = "config"
+const (
+	ConfigName = "config"
+	// EnvPrefix 配置相关环境变量的前缀
+	EnvPrefix = "DDD"
+)
 
 var (
 	// 错误响应码
@@ -15,5 +19,56 @@ var (
 	ErrMsgResponseCode = errors.New("invalid response code")
 
 	ErrCacheResultTypeMismatched = errors.New("result type mismatched")
+	ErrCacheNotFound             = errors.New("not found")
+	ErrCacheLoaderPanic          = errors.New("cache loader panicked")
+	ErrCacheLoaderTimeout        = errors.New("cache loader timeout")
+	ErrCacheWarmupPending        = errors.New("cache warmup pending")
 	ErrESQueryIndexData          = errors.New("query index data error")
+	ErrESNewClient               = errors.New("create es client error")
+	ErrESPing                    = errors.New("ping es error")
+
+	ErrFactoryNotFound    = errors.New("object not found in factory")
+	ErrFactoryUnhealthy   = errors.New("factory objects are unhealthy")
+	ErrFactoryDestroy     = errors.New("destroy factory objects error")
+	ErrFactoryCreatePanic = errors.New("create factory object panic")
+
+	ErrLockNotAcquired = errors.New("lock is held by another owner")
+	ErrLockNotHeld     = errors.New("lock is not held")
+
+	ErrRateLimitInvalid = errors.New("invalid rate limit")
+
+	ErrRepositoryNotFound      = errors.New("entity not found")
+	ErrRepositoryMissingID     = errors.New("entity id is required")
+	ErrRepositoryUnknownField  = errors.New("unknown entity field")
+	ErrRepositoryUnsupported   = errors.New("unsupported specification")
+	ErrRepositoryInvalidDest   = errors.New("dest must be a pointer to slice of entities")
+	ErrRepositoryInvalidCursor = errors.New("invalid cursor")
+	ErrRepositoryInvalidQuery  = errors.New("invalid query")
+
+	ErrMigrationInvalidName    = errors.New("invalid migration file name")
+	ErrMigrationDuplicate      = errors.New("duplicate migration version")
+	ErrMigrationChecksum       = errors.New("migration checksum mismatched")
+	ErrMigrationMissingFile    = errors.New("applied migration file is missing")
+	ErrMigrationIrreversible   = errors.New("migration has no down file")
+	ErrMigrationLockTimeout    = errors.New("acquire migration lock timeout")
+	ErrMigrationUnknownCommand = errors.New("unknown migrate command")
+	ErrMigrationInvalidSteps   = errors.New("migrate down steps must be at least 1")
+
+	ErrSerializerInvalidHeader     = errors.New("invalid serialized data header")
+	ErrSerializerVersionMismatched = errors.New("serialized data codec or schema version mismatched")
+	ErrSerializerUnknownCompressor = errors.New("unknown compressor of serialized data")
+
+	ErrConfigFlagWithoutValue   = errors.New("config flag without value")
+	ErrConfigInvalid            = errors.New("invalid config")
+	ErrConfigUnmarshalTarget    = errors.New("config unmarshal target must be a non-nil pointer to struct")
+	ErrConfigUnsupportedDefault = errors.New("unsupported default value type")
+	ErrConfigSecretKeyMissing   = errors.New("config secret key is missing")
+	ErrConfigSecretDecrypt      = errors.New("decrypt config secret error")
+	ErrConfigFileNotFound       = errors.New("config file not found")
+	ErrConfigUnsupportedType    = errors.New("unsupported config file type")
+	ErrConfigRemoteUnavailable  = errors.New("remote config source is unavailable and no local cache")
+	ErrConfigWatchUnsupported   = errors.New("remote config source does not support watch")
+
+	ErrEncryptInvalidCiphertext = errors.New("invalid ciphertext")
+	ErrEncryptInvalidPadding    = errors.New("invalid padding")
 )
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"ddd-demo/common/consts"
 )
 
 // AES 加密
@@ -22,10 +23,16 @@ func pKCS7Padding(ciphertext []byte, blockSize int) []byte {
 	return append(ciphertext, padtext...)
 }
 
-func pKCS7UnPadding(origData []byte) []byte {
+func pKCS7UnPadding(origData []byte, blockSize int) ([]byte, error) {
 	length := len(origData)
+	if length == 0 {
+		return nil, consts.ErrEncryptInvalidPadding
+	}
 	unpadding := int(origData[length-1])
-	return origData[:(length - unpadding)]
+	if unpadding == 0 || unpadding > blockSize || unpadding > length {
+		return nil, consts.ErrEncryptInvalidPadding
+	}
+	return origData[:(length - unpadding)], nil
 }
 
 // Encrypt 加密数据
@@ -49,9 +56,11 @@ func (a *AES) Decrypt(crypted []byte) ([]byte, error) {
 		return nil, err
 	}
 	blockSize := block.BlockSize()
+	if len(crypted) == 0 || len(crypted)%blockSize != 0 {
+		return nil, consts.ErrEncryptInvalidCiphertext
+	}
 	blockMode := cipher.NewCBCDecrypter(block, a.Key[:blockSize])
 	origData := make([]byte, len(crypted))
 	blockMode.CryptBlocks(origData, crypted)
-	origData = pKCS7UnPadding(origData)
-	return origData, nil
+	return pKCS7UnPadding(origData, blockSize)
 }
@@ -0,0 +1,26 @@
+package factory
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+)
+
+// Module 提供默认的 ObjectFactory 单例，启动后定时检查缓存对象的健康状态（只移除允许移除的对象），
+// 并在应用停止时销毁其中缓存的对象。
+// 基础设施包中的 fx.Module 都依赖该模块
+var Module = fx.Module(
+	"factory",
+	fx.Provide(NewObjectFactory),
+	fx.Invoke(func(lc fx.Lifecycle, factory *ObjectFactory) {
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				factory.StartHealthCheck(DefaultHealthCheckInterval)
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				return factory.Destroy()
+			},
+		})
+	}),
+)
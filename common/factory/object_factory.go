@@ -1,69 +1,312 @@
 package factory
 
-import "sync"
+import (
+	"context"
+	"ddd-demo/common/consts"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCreateTimeout 默认的创建对象超时时间
+	DefaultCreateTimeout = 30 * time.Second
+	// DefaultHealthCheckInterval 默认的健康检查间隔
+	DefaultHealthCheckInterval = 30 * time.Second
+)
+
+// Provider 对象的创建、销毁以及健康检查
+type Provider struct {
+	// Create 创建对象，ctx 带有 ObjectFactory.CreateTimeout 超时
+	Create func(ctx context.Context, key string) (interface{}, error)
+	// Destroy 销毁对象，可以为空
+	Destroy func(obj interface{}) error
+	// Check 检查缓存的对象是否可用，可以为空。检查失败默认只报告，不影响缓存的对象
+	Check func(ctx context.Context, obj interface{}) error
+	// EvictOnFailure 为 true 时检查失败的对象被移除并销毁，下次获取时重新创建。
+	// 只能用于每次使用时都通过工厂获取的对象，已经注入到其他组件中的对象被销毁后无法恢复
+	EvictOnFailure bool
+}
 
-// ObjectFactory 对象工厂
+// entry 缓存的对象，ready 关闭后 obj 与 err 才可以读取
+type entry struct {
+	provider *Provider
+	ready    chan struct{}
+	obj      interface{}
+	err      error
+}
+
+// destroy 销毁 entry 中的对象
+func (e *entry) destroy() error {
+	if e.err != nil || e.obj == nil || e.provider.Destroy == nil {
+		return nil
+	}
+	return e.provider.Destroy(e.obj)
+}
+
+// ObjectFactory 对象工厂，按 key 缓存对象，同一个 key 的对象只创建一次，
+// 创建时不阻塞其他 key 的获取。零值可以直接使用
 type ObjectFactory struct {
-	keyToObject      map[string]interface{}
-	keyToDestroyFunc map[string]func(interface{}) error
+	// CreateTimeout 创建对象的超时时间，为 0 时使用 DefaultCreateTimeout
+	CreateTimeout time.Duration
+
 	// mu 读写锁
-	mu sync.RWMutex
+	mu      sync.RWMutex
+	entries map[string]*entry
+
+	// healthMu 保护 stop 以及 done，健康检查未开始时为 nil
+	healthMu sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
 }
 
-// Get 获取对象
+// Get 获取对象，不存在时通过 createFunc 创建
 func (o *ObjectFactory) Get(key string, createFunc func(string) (interface{}, error),
 	destroyFunc func(interface{}) error) (interface{}, error) {
+	return o.GetContext(context.Background(), key, &Provider{
+		Create: func(_ context.Context, key string) (interface{}, error) {
+			return createFunc(key)
+		},
+		Destroy: destroyFunc,
+	})
+}
+
+// GetContext 获取对象，不存在时通过 provider 创建。同一个 key 并发获取时只创建一次，
+// 其他调用等待创建完成，ctx 结束时不再等待。创建失败的对象不会被缓存
+func (o *ObjectFactory) GetContext(ctx context.Context, key string, provider *Provider) (interface{}, error) {
 	// 如果已创建过对象，那么直接返回
 	o.mu.RLock()
-	obj, found := o.keyToObject[key]
+	e, found := o.entries[key]
 	o.mu.RUnlock()
-	if found {
-		return obj, nil
+
+	if !found {
+		// 加写锁，双重检查，防止重复创建
+		o.mu.Lock()
+		if e, found = o.entries[key]; !found {
+			if o.entries == nil {
+				o.entries = make(map[string]*entry)
+			}
+			e = &entry{provider: provider, ready: make(chan struct{})}
+			o.entries[key] = e
+		}
+		o.mu.Unlock()
+		if !found {
+			o.create(ctx, key, e)
+		}
+	}
+
+	select {
+	case <-e.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.obj, nil
+}
+
+// create 创建 entry 中的对象，失败或者 panic 时将 entry 从缓存中移除
+func (o *ObjectFactory) create(ctx context.Context, key string, e *entry) {
+	defer close(e.ready)
+	defer func() {
+		if p := recover(); p != nil {
+			e.obj, e.err = nil, fmt.Errorf("%w: %s: %v", consts.ErrFactoryCreatePanic, redactKey(key), p)
+		}
+		if e.err != nil {
+			o.mu.Lock()
+			if o.entries[key] == e {
+				delete(o.entries, key)
+			}
+			o.mu.Unlock()
+		}
+	}()
+	e.obj, e.err = o.createObject(ctx, key, e.provider)
+}
+
+// createObject 在超时时间内创建对象
+func (o *ObjectFactory) createObject(ctx context.Context, key string, provider *Provider) (interface{}, error) {
+	timeout := o.CreateTimeout
+	if timeout <= 0 {
+		timeout = DefaultCreateTimeout
 	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return provider.Create(ctx, key)
+}
 
-	// 加写锁
+// Remove 从缓存中移除并销毁 key 对应的对象，正在创建的对象会在创建完成后销毁。
+// 已经获取到该对象的调用方需要重新获取
+func (o *ObjectFactory) Remove(key string) error {
 	o.mu.Lock()
-	defer o.mu.Unlock()
+	e, found := o.entries[key]
+	delete(o.entries, key)
+	o.mu.Unlock()
+	if !found {
+		return nil
+	}
+	<-e.ready
+	return e.destroy()
+}
 
-	// 双重检查，防止重复创建
-	if obj, found := o.keyToObject[key]; found {
-		return obj, nil
+// Refresh 使用创建时的 provider 重新创建 key 对应的对象，创建成功后替换缓存并销毁旧对象，
+// 创建失败时保留旧对象。key 不存在时返回 ErrFactoryNotFound。
+// 旧对象销毁失败时同时返回新对象以及销毁的错误，新对象已经生效
+func (o *ObjectFactory) Refresh(ctx context.Context, key string) (interface{}, error) {
+	o.mu.RLock()
+	old, found := o.entries[key]
+	o.mu.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("%w: %s", consts.ErrFactoryNotFound, redactKey(key))
+	}
+	select {
+	case <-old.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 
-	// 创建实例
-	obj, err := createFunc(key)
+	obj, err := o.createObject(ctx, key, old.provider)
 	if err != nil {
 		return nil, err
 	}
+	e := &entry{provider: old.provider, ready: make(chan struct{}), obj: obj}
+	close(e.ready)
 
-	// 缓存实例
-	o.keyToObject[key] = obj
-	if destroyFunc != nil {
-		o.keyToDestroyFunc[key] = destroyFunc
+	o.mu.Lock()
+	replaced := o.entries[key]
+	if o.entries == nil {
+		o.entries = make(map[string]*entry)
+	}
+	o.entries[key] = e
+	o.mu.Unlock()
+	if replaced != nil {
+		<-replaced.ready
+		if err := replaced.destroy(); err != nil {
+			return obj, fmt.Errorf("destroy replaced object %s: %w", redactKey(key), err)
+		}
 	}
-
 	return obj, nil
 }
 
-// Destroy 销毁工厂及其中缓存的对象
-func (o *ObjectFactory) Destroy() {
-	o.mu.Lock()
-	defer o.mu.Unlock()
+// CheckHealth 检查缓存的对象，返回检查失败的原因。只有 Provider.EvictOnFailure 为 true 的对象在检查失败时被移除并销毁
+func (o *ObjectFactory) CheckHealth(ctx context.Context) error {
+	o.mu.RLock()
+	keys := make([]string, 0, len(o.entries))
+	entries := make([]*entry, 0, len(o.entries))
+	for key, e := range o.entries {
+		select {
+		case <-e.ready:
+		default:
+			continue
+		}
+		if e.err == nil && e.provider.Check != nil {
+			keys = append(keys, key)
+			entries = append(entries, e)
+		}
+	}
+	o.mu.RUnlock()
+
+	var problems []string
+	for i, e := range entries {
+		if err := e.provider.Check(ctx, e.obj); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", redactKey(keys[i]), err))
+			if !e.provider.EvictOnFailure {
+				continue
+			}
+			o.mu.Lock()
+			invalidated := o.entries[keys[i]] == e
+			if invalidated {
+				delete(o.entries, keys[i])
+			}
+			o.mu.Unlock()
+			if invalidated {
+				_ = e.destroy()
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%w: %s", consts.ErrFactoryUnhealthy, strings.Join(problems, "; "))
+	}
+	return nil
+}
 
-	if o.keyToObject == nil {
+// StartHealthCheck 在后台每隔 interval 调用一次 CheckHealth，输出检查失败的原因并移除允许移除的对象，
+// 直到调用 StopHealthCheck 或者 Destroy
+func (o *ObjectFactory) StartHealthCheck(interval time.Duration) {
+	o.healthMu.Lock()
+	defer o.healthMu.Unlock()
+	if o.stop != nil || interval <= 0 {
 		return
 	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	o.stop, o.done = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if err := o.CheckHealth(ctx); err != nil {
+					fmt.Println(err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
 
-	for key, obj := range o.keyToObject {
-		if obj == nil {
-			continue
+// StopHealthCheck 停止健康检查，并等待后台协程退出
+func (o *ObjectFactory) StopHealthCheck() {
+	o.healthMu.Lock()
+	stop, done := o.stop, o.done
+	o.stop, o.done = nil, nil
+	o.healthMu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Destroy 停止健康检查并销毁缓存的所有对象，返回所有销毁失败的原因。销毁后工厂仍然可以使用
+func (o *ObjectFactory) Destroy() error {
+	o.StopHealthCheck()
+
+	o.mu.Lock()
+	entries := o.entries
+	o.entries = nil
+	o.mu.Unlock()
+
+	var problems []string
+	for key, e := range entries {
+		<-e.ready
+		if err := e.destroy(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", redactKey(key), err))
 		}
-		if destroyFunc, found := o.keyToDestroyFunc[key]; found {
-			_ = destroyFunc(obj)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%w: %s", consts.ErrFactoryDestroy, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// redactKey 去掉 key 中的用户名与密码，key 通常是 user:password@host 形式的连接串
+func redactKey(key string) string {
+	if index := strings.LastIndex(key, "@"); index >= 0 {
+		prefix := ""
+		if schemeIndex := strings.Index(key[:index], "://"); schemeIndex >= 0 {
+			prefix = key[:schemeIndex+3]
 		}
+		return prefix + "***" + key[index:]
 	}
-	o.keyToObject = nil
-	o.keyToDestroyFunc = nil
+	return key
 }
 
 var (
@@ -74,9 +317,12 @@ var (
 // NewObjectFactory 获取默认的 ObjectFactory 单例
 func NewObjectFactory() *ObjectFactory {
 	defaultObjectFactoryOnce.Do(func() {
-		defaultObjectFactory = &ObjectFactory{
-			keyToObject:      make(map[string]interface{}),
-			keyToDestroyFunc: make(map[string]func(interface{}) error)}
+		defaultObjectFactory = NewIsolatedObjectFactory()
 	})
 	return defaultObjectFactory
 }
+
+// NewIsolatedObjectFactory 创建独立的 ObjectFactory，与默认单例互不影响，比如用于测试或者一次性的命令
+func NewIsolatedObjectFactory() *ObjectFactory {
+	return &ObjectFactory{entries: make(map[string]*entry)}
+}
@@ -0,0 +1,90 @@
+package serializer
+
+import (
+	"encoding/json"
+
+	"github.com/ugorji/go/codec"
+)
+
+// 内置编解码器的 ID，会被写入到 VersionedCodec 的版本头中，自定义编解码器应使用 128 及以上的 ID
+const (
+	CodecIDGob     byte = 1
+	CodecIDJSON    byte = 2
+	CodecIDMsgpack byte = 3
+)
+
+// Codec 序列化编解码器
+type Codec interface {
+	// ID 编解码器的唯一标识
+	ID() byte
+	// Marshal 序列化指定的对象
+	Marshal(src interface{}) ([]byte, error)
+	// Unmarshal 反序列化到指定的对象
+	Unmarshal(buf []byte, dest interface{}) error
+}
+
+// GobCodec 使用 gob 的编解码器
+type GobCodec struct{}
+
+// ID 编解码器的唯一标识
+func (GobCodec) ID() byte {
+	return CodecIDGob
+}
+
+// Marshal 使用 gob 序列化指定的对象
+func (GobCodec) Marshal(src interface{}) ([]byte, error) {
+	return GobEncode(src)
+}
+
+// Unmarshal 使用 gob 反序列化指定的对象
+func (GobCodec) Unmarshal(buf []byte, dest interface{}) error {
+	return GobDecode(dest, buf)
+}
+
+// JSONCodec 使用 JSON 的编解码器，结构变化时兼容性较好，并且便于在 Redis 等存储中直接查看
+type JSONCodec struct{}
+
+// ID 编解码器的唯一标识
+func (JSONCodec) ID() byte {
+	return CodecIDJSON
+}
+
+// Marshal 使用 JSON 序列化指定的对象
+func (JSONCodec) Marshal(src interface{}) ([]byte, error) {
+	return json.Marshal(src)
+}
+
+// Unmarshal 使用 JSON 反序列化指定的对象
+func (JSONCodec) Unmarshal(buf []byte, dest interface{}) error {
+	return json.Unmarshal(buf, dest)
+}
+
+// msgpackHandle msgpack 编解码配置，可被并发使用
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+func init() {
+	// 解码到 interface{} 时，将原始字节转换为字符串
+	msgpackHandle.RawToString = true
+}
+
+// MsgpackCodec 使用 msgpack 的编解码器，体积小，对大 map 的编解码速度快
+type MsgpackCodec struct{}
+
+// ID 编解码器的唯一标识
+func (MsgpackCodec) ID() byte {
+	return CodecIDMsgpack
+}
+
+// Marshal 使用 msgpack 序列化指定的对象
+func (MsgpackCodec) Marshal(src interface{}) ([]byte, error) {
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, msgpackHandle).Encode(src); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Unmarshal 使用 msgpack 反序列化指定的对象
+func (MsgpackCodec) Unmarshal(buf []byte, dest interface{}) error {
+	return codec.NewDecoderBytes(buf, msgpackHandle).Decode(dest)
+}
@@ -0,0 +1,85 @@
+package serializer
+
+import (
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 内置压缩算法的 ID，会被写入到 VersionedCodec 的版本头中，0 表示未压缩
+const (
+	CompressorIDNone   byte = 0
+	CompressorIDSnappy byte = 1
+	CompressorIDZstd   byte = 2
+)
+
+// Compressor 压缩算法
+type Compressor interface {
+	// ID 压缩算法的唯一标识，不能为 0
+	ID() byte
+	// Compress 压缩数据
+	Compress(src []byte) ([]byte, error)
+	// Decompress 解压数据
+	Decompress(src []byte) ([]byte, error)
+}
+
+// SnappyCompressor 使用 snappy 的压缩算法，速度快，压缩率一般
+type SnappyCompressor struct{}
+
+// ID 压缩算法的唯一标识
+func (SnappyCompressor) ID() byte {
+	return CompressorIDSnappy
+}
+
+// Compress 压缩数据
+func (SnappyCompressor) Compress(src []byte) ([]byte, error) {
+	return snappy.Encode(nil, src), nil
+}
+
+// Decompress 解压数据
+func (SnappyCompressor) Decompress(src []byte) ([]byte, error) {
+	return snappy.Decode(nil, src)
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// initZstd 创建可被并发使用的 zstd 编码器与解码器
+func initZstd() error {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdErr
+}
+
+// ZstdCompressor 使用 zstd 的压缩算法，压缩率高
+type ZstdCompressor struct{}
+
+// ID 压缩算法的唯一标识
+func (ZstdCompressor) ID() byte {
+	return CompressorIDZstd
+}
+
+// Compress 压缩数据
+func (ZstdCompressor) Compress(src []byte) ([]byte, error) {
+	if err := initZstd(); err != nil {
+		return nil, err
+	}
+	return zstdEncoder.EncodeAll(src, make([]byte, 0, len(src))), nil
+}
+
+// Decompress 解压数据
+func (ZstdCompressor) Decompress(src []byte) ([]byte, error) {
+	if err := initZstd(); err != nil {
+		return nil, err
+	}
+	return zstdDecoder.DecodeAll(src, nil)
+}
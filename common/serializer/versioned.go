@@ -0,0 +1,109 @@
+package serializer
+
+import (
+	"ddd-demo/common/consts"
+	"encoding/binary"
+)
+
+const (
+	// headerMagic 版本头的魔数
+	headerMagic byte = 0xDD
+	// headerVersion 版本头格式的版本
+	headerVersion byte = 1
+	// headerSize 版本头的长度：魔数、格式版本、编解码器 ID、压缩算法 ID 各 1 字节，Schema 版本 4 字节
+	headerSize = 8
+	// DefaultCompressThreshold 默认超过 4K 的数据才进行压缩
+	DefaultCompressThreshold = 4096
+)
+
+// VersionedCodec 在序列化结果前写入版本头，记录编解码器、压缩算法和 Schema 版本。
+// 反序列化时如果编解码器或者 Schema 版本与当前不一致，那么返回 consts.ErrSerializerVersionMismatched，
+// 调用方可以据此忽略部署前写入的旧数据
+type VersionedCodec struct {
+	// Codec 编解码器，为 nil 时使用 GobCodec
+	Codec Codec
+	// SchemaVersion 数据结构的版本，数据结构发生不兼容的变化时应递增
+	SchemaVersion uint32
+	// Compressor 压缩算法，为 nil 时不压缩
+	Compressor Compressor
+	// CompressThreshold 超过该大小（字节）的数据才进行压缩，小于等于 0 时使用 DefaultCompressThreshold
+	CompressThreshold int
+}
+
+// NewVersionedCodec 创建带版本头的编解码器
+func NewVersionedCodec(codec Codec, schemaVersion uint32) *VersionedCodec {
+	return &VersionedCodec{Codec: codec, SchemaVersion: schemaVersion}
+}
+
+// codec 返回实际使用的编解码器
+func (v *VersionedCodec) codec() Codec {
+	if v.Codec == nil {
+		return GobCodec{}
+	}
+	return v.Codec
+}
+
+// ID 编解码器的唯一标识
+func (v *VersionedCodec) ID() byte {
+	return v.codec().ID()
+}
+
+// Marshal 序列化指定的对象，数据较大时进行压缩，并在最前面写入版本头
+func (v *VersionedCodec) Marshal(src interface{}) ([]byte, error) {
+	payload, err := v.codec().Marshal(src)
+	if err != nil {
+		return nil, err
+	}
+
+	compressorID := CompressorIDNone
+	threshold := v.CompressThreshold
+	if threshold <= 0 {
+		threshold = DefaultCompressThreshold
+	}
+	if v.Compressor != nil && len(payload) > threshold {
+		if payload, err = v.Compressor.Compress(payload); err != nil {
+			return nil, err
+		}
+		compressorID = v.Compressor.ID()
+	}
+
+	buf := make([]byte, headerSize, headerSize+len(payload))
+	buf[0] = headerMagic
+	buf[1] = headerVersion
+	buf[2] = v.codec().ID()
+	buf[3] = compressorID
+	binary.BigEndian.PutUint32(buf[4:headerSize], v.SchemaVersion)
+	return append(buf, payload...), nil
+}
+
+// Unmarshal 校验版本头，必要时解压，然后反序列化到指定的对象
+func (v *VersionedCodec) Unmarshal(buf []byte, dest interface{}) error {
+	if len(buf) < headerSize || buf[0] != headerMagic || buf[1] != headerVersion {
+		return consts.ErrSerializerInvalidHeader
+	}
+	if buf[2] != v.codec().ID() || binary.BigEndian.Uint32(buf[4:headerSize]) != v.SchemaVersion {
+		return consts.ErrSerializerVersionMismatched
+	}
+
+	payload := buf[headerSize:]
+	switch buf[3] {
+	case CompressorIDNone:
+	case compressorID(v.Compressor):
+		var err error
+		if payload, err = v.Compressor.Decompress(payload); err != nil {
+			return err
+		}
+	default:
+		return consts.ErrSerializerUnknownCompressor
+	}
+
+	return v.codec().Unmarshal(payload, dest)
+}
+
+// compressorID 返回压缩算法的 ID，未设置压缩算法时返回 CompressorIDNone
+func compressorID(c Compressor) byte {
+	if c == nil {
+		return CompressorIDNone
+	}
+	return c.ID()
+}
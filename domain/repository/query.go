@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"ddd-demo/common/consts"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query 查询条件、排序以及分页，通常由接口层解析请求参数得到，通过 Find 执行
+type Query struct {
+	// Spec 查询条件，为 nil 时查询全部实体
+	Spec Specification
+	// Sort 排序条件，游标分页时不能为空，并且最后一个字段需要唯一，比如 ID
+	Sort []Order
+	// Size 每页的数量
+	Size int
+	// Page 偏移分页的页码，从 1 开始
+	Page int
+	// Cursor 是否使用游标分页
+	Cursor bool
+	// After 游标分页时上一页返回的 Page.NextCursor，为空时查询第一页
+	After string
+}
+
+// Find 执行查询并将实体写入 dest。偏移分页直接调用 FindBy；游标分页将游标转换为排序字段上的条件，
+// 并多查询一个实体判断是否有下一页（不统计总数），因此所有的仓储实现都支持游标分页。
+// 游标分页的排序字段需要是实体结构体的顶层字段，值不能为空，类型为数字、字符串、布尔值、时间，
+// 或者实现了 encoding.TextMarshaler 以及 encoding.TextUnmarshaler 的类型（比如 MongoDB 的 ObjectID）
+func Find(ctx context.Context, repo Repository, query *Query, dest interface{}) (*Page, error) {
+	if !query.Cursor {
+		return repo.FindBy(ctx, query.Spec, &PageRequest{Page: query.Page, Size: query.Size, Sort: query.Sort}, dest)
+	}
+	if len(query.Sort) == 0 || query.Size <= 0 {
+		return nil, fmt.Errorf("%w: cursor pagination requires sort and size", consts.ErrRepositoryInvalidQuery)
+	}
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return nil, consts.ErrRepositoryInvalidDest
+	}
+
+	spec := query.Spec
+	if query.After != "" {
+		values, err := decodeCursor(query.After, query.Sort, slice.Elem().Type().Elem())
+		if err != nil {
+			return nil, err
+		}
+		spec = keyset(query.Sort, values)
+		if query.Spec != nil {
+			spec = And(query.Spec, spec)
+		}
+	}
+	entities := slice.Elem()
+	entities.SetLen(0)
+	if _, err := repo.FindBy(ctx, spec, &PageRequest{Page: 1, Size: query.Size + 1, Sort: query.Sort, SkipCount: true}, dest); err != nil {
+		return nil, err
+	}
+
+	page := &Page{Size: query.Size}
+	if entities.Len() > query.Size {
+		entities.Set(entities.Slice(0, query.Size))
+		cursor, err := encodeCursor(entities.Index(query.Size-1).Interface(), query.Sort)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = cursor
+	}
+	return page, nil
+}
+
+// keyset 排序在 values 之后的实体的条件，比如按 (a, b) 升序时为 a > va OR (a = va AND b > vb)
+func keyset(sort []Order, values []interface{}) Specification {
+	specs := make([]Specification, 0, len(sort))
+	for i, order := range sort {
+		conditions := make([]Specification, 0, i+1)
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, Eq(sort[j].Field, values[j]))
+		}
+		if order.Desc {
+			conditions = append(conditions, Lt(order.Field, values[i]))
+		} else {
+			conditions = append(conditions, Gt(order.Field, values[i]))
+		}
+		specs = append(specs, And(conditions...))
+	}
+	return Or(specs...)
+}
+
+// cursor 游标的内容，Fields 用于发现排序条件与游标不一致
+type cursor struct {
+	Fields []string      `json:"f"`
+	Values []cursorValue `json:"v"`
+}
+
+// cursorValue 带类型的排序字段值，解码后保持原来的类型
+type cursorValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v"`
+}
+
+// cursorFields 排序条件在游标中的表示，降序的字段带 - 前缀
+func cursorFields(sort []Order) []string {
+	fields := make([]string, len(sort))
+	for i, order := range sort {
+		fields[i] = order.Field
+		if order.Desc {
+			fields[i] = "-" + order.Field
+		}
+	}
+	return fields
+}
+
+// encodeCursor 将实体的排序字段值编码为不透明的游标
+func encodeCursor(entity interface{}, sort []Order) (string, error) {
+	c := cursor{Fields: cursorFields(sort)}
+	for _, order := range sort {
+		value, err := entityField(entity, order.Field)
+		if err != nil {
+			return "", err
+		}
+		encoded, err := encodeCursorValue(value)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", err, order.Field)
+		}
+		c.Values = append(c.Values, encoded)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor 解码游标中的排序字段值，entityType 用于获取文本编码的字段类型，
+// 游标与排序条件不一致时返回 ErrRepositoryInvalidCursor
+func decodeCursor(s string, sort []Order, entityType reflect.Type) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, consts.ErrRepositoryInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, consts.ErrRepositoryInvalidCursor
+	}
+	if strings.Join(c.Fields, ",") != strings.Join(cursorFields(sort), ",") || len(c.Values) != len(sort) {
+		return nil, fmt.Errorf("%w: sort mismatched", consts.ErrRepositoryInvalidCursor)
+	}
+	values := make([]interface{}, len(c.Values))
+	for i, encoded := range c.Values {
+		if values[i], err = decodeCursorValue(encoded, entityType, sort[i].Field); err != nil {
+			return nil, fmt.Errorf("%w: %s", consts.ErrRepositoryInvalidCursor, sort[i].Field)
+		}
+	}
+	return values, nil
+}
+
+// encodeCursorValue 编码排序字段值
+func encodeCursorValue(value interface{}) (cursorValue, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.IsValid() && v.CanInterface() {
+		if t, isTime := v.Interface().(time.Time); isTime {
+			return cursorValue{Type: "time", Value: t.Format(time.RFC3339Nano)}, nil
+		}
+		if marshaler, isText := v.Interface().(encoding.TextMarshaler); isText {
+			text, err := marshaler.MarshalText()
+			if err != nil {
+				return cursorValue{}, err
+			}
+			return cursorValue{Type: "text", Value: string(text)}, nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return cursorValue{Type: "string", Value: v.String()}, nil
+	case reflect.Bool:
+		return cursorValue{Type: "bool", Value: strconv.FormatBool(v.Bool())}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cursorValue{Type: "int", Value: strconv.FormatInt(v.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cursorValue{Type: "uint", Value: strconv.FormatUint(v.Uint(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		return cursorValue{Type: "float", Value: strconv.FormatFloat(v.Float(), 'g', -1, 64)}, nil
+	}
+	return cursorValue{}, fmt.Errorf("%w: cursor value %T", consts.ErrRepositoryUnsupported, value)
+}
+
+// decodeCursorValue 解码排序字段值，文本编码的值按实体 entityType 的字段 field 的类型解码
+func decodeCursorValue(encoded cursorValue, entityType reflect.Type, field string) (interface{}, error) {
+	switch encoded.Type {
+	case "text":
+		fieldType, err := entityFieldType(entityType, field)
+		if err != nil {
+			return nil, err
+		}
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		value := reflect.New(fieldType)
+		unmarshaler, isText := value.Interface().(encoding.TextUnmarshaler)
+		if !isText {
+			return nil, consts.ErrRepositoryInvalidCursor
+		}
+		if err := unmarshaler.UnmarshalText([]byte(encoded.Value)); err != nil {
+			return nil, err
+		}
+		return value.Elem().Interface(), nil
+	case "time":
+		return time.Parse(time.RFC3339Nano, encoded.Value)
+	case "string":
+		return encoded.Value, nil
+	case "bool":
+		return strconv.ParseBool(encoded.Value)
+	case "int":
+		return strconv.ParseInt(encoded.Value, 10, 64)
+	case "uint":
+		return strconv.ParseUint(encoded.Value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(encoded.Value, 64)
+	}
+	return nil, consts.ErrRepositoryInvalidCursor
+}
+
+// entityField 获取实体结构体的顶层字段值，字段名不区分大小写并忽略下划线，因此列名 created_at 也可以匹配 CreatedAt
+func entityField(entity interface{}, name string) (interface{}, error) {
+	value := reflect.ValueOf(entity)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Struct {
+		field := value.FieldByNameFunc(func(fieldName string) bool {
+			return normalizeField(fieldName) == normalizeField(name)
+		})
+		if field.IsValid() && field.CanInterface() {
+			return field.Interface(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", consts.ErrRepositoryUnknownField, name)
+}
+
+// entityFieldType 获取实体类型的顶层字段类型，字段名的匹配规则与 entityField 相同
+func entityFieldType(entityType reflect.Type, name string) (reflect.Type, error) {
+	for entityType.Kind() == reflect.Ptr {
+		entityType = entityType.Elem()
+	}
+	if entityType.Kind() == reflect.Struct {
+		field, found := entityType.FieldByNameFunc(func(fieldName string) bool {
+			return normalizeField(fieldName) == normalizeField(name)
+		})
+		if found && field.PkgPath == "" {
+			return field.Type, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", consts.ErrRepositoryUnknownField, name)
+}
+
+// normalizeField 字段名转换为小写并去掉下划线
+func normalizeField(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, "_", ""))
+}
@@ -0,0 +1,93 @@
+package repository
+
+import "context"
+
+// Entity 可以被仓储保存的实体，通常是聚合根
+type Entity interface {
+	// Identity 实体的唯一标识
+	Identity() interface{}
+}
+
+// Repository 聚合的仓储，领域服务通过它读写聚合，而不直接使用数据库驱动
+type Repository interface {
+	// FindByID 根据 ID 查询实体并写入 dest，不存在时返回 consts.ErrRepositoryNotFound
+	FindByID(ctx context.Context, id interface{}, dest Entity) error
+	// Save 保存实体，不存在时插入，存在时更新
+	Save(ctx context.Context, entity Entity) error
+	// Delete 根据 ID 删除实体，不存在时不返回错误
+	Delete(ctx context.Context, id interface{}) error
+	// FindBy 查询满足 spec 的实体并写入 dest，dest 为指向实体切片的指针，比如 *[]*User。
+	// spec 为 nil 时查询全部实体，pageable 为 nil 时不分页
+	FindBy(ctx context.Context, spec Specification, pageable *PageRequest, dest interface{}) (*Page, error)
+}
+
+// Order 排序条件
+type Order struct {
+	// Field 排序的字段，使用实体结构体的字段名
+	Field string
+	// Desc 是否降序
+	Desc bool
+}
+
+// PageRequest 分页与排序条件
+type PageRequest struct {
+	// Page 页码，从 1 开始
+	Page int
+	// Size 每页的数量，小于等于 0 时不分页
+	Size int
+	// Sort 排序条件，按顺序依次比较
+	Sort []Order
+	// SkipCount 为 true 时不统计满足条件的实体总数，Page.Total 为 0，用于不需要总数的游标分页等场景
+	SkipCount bool
+}
+
+// Offset 分页的偏移量
+func (p *PageRequest) Offset() int {
+	if p == nil || p.Size <= 0 || p.Page <= 1 {
+		return 0
+	}
+	return (p.Page - 1) * p.Size
+}
+
+// Limit 分页的数量，不分页时返回 0
+func (p *PageRequest) Limit() int {
+	if p == nil || p.Size <= 0 {
+		return 0
+	}
+	return p.Size
+}
+
+// Page 分页查询的结果，查询到的实体写入 FindBy 的 dest 中
+type Page struct {
+	// Page 页码，从 1 开始，游标分页时为 0
+	Page int
+	// Size 每页的数量，为 0 表示不分页
+	Size int
+	// Total 满足条件的实体总数，游标分页时为 0
+	Total int64
+	// NextCursor 游标分页时下一页的游标，没有下一页时为空
+	NextCursor string
+}
+
+// NewPage 创建分页查询的结果
+func NewPage(pageable *PageRequest, total int64) *Page {
+	page := &Page{Page: 1, Total: total}
+	if limit := pageable.Limit(); limit > 0 {
+		page.Size = limit
+		if pageable.Page > 1 {
+			page.Page = pageable.Page
+		}
+	}
+	return page
+}
+
+// Pages 总页数
+func (p *Page) Pages() int {
+	if p.Size <= 0 {
+		if p.Total > 0 {
+			return 1
+		}
+		return 0
+	}
+	return int((p.Total + int64(p.Size) - 1) / int64(p.Size))
+}
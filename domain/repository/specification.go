@@ -0,0 +1,134 @@
+package repository
+
+import "reflect"
+
+// Specification 查询条件，由 Eq、And 等函数构造，各个仓储实现将其翻译为自己的查询语言
+type Specification interface {
+	isSpecification()
+}
+
+// Operator 字段条件的运算符
+type Operator string
+
+// 字段条件的运算符
+const (
+	// OpEq 等于，Value 为 nil 时匹配空值
+	OpEq Operator = "eq"
+	// OpNe 不等于，字段为空值时也满足，Value 为 nil 时匹配非空值
+	OpNe Operator = "ne"
+	// OpGt 大于
+	OpGt Operator = "gt"
+	// OpGte 大于等于
+	OpGte Operator = "gte"
+	// OpLt 小于
+	OpLt Operator = "lt"
+	// OpLte 小于等于
+	OpLte Operator = "lte"
+	// OpIn 等于 Value 中的任意一个，Value 为 []interface{}
+	OpIn Operator = "in"
+	// OpLike 匹配 SQL LIKE 模式，% 匹配任意多个字符，_ 匹配一个字符，\ 转义，不区分大小写
+	OpLike Operator = "like"
+)
+
+// Condition 字段条件
+type Condition struct {
+	// Field 字段，使用实体结构体的字段名
+	Field string
+	// Operator 运算符
+	Operator Operator
+	// Value 比较的值
+	Value interface{}
+}
+
+func (*Condition) isSpecification() {}
+
+// Logic 组合条件的逻辑运算
+type Logic string
+
+// 组合条件的逻辑运算
+const (
+	// LogicAnd 所有条件都满足
+	LogicAnd Logic = "and"
+	// LogicOr 任意一个条件满足
+	LogicOr Logic = "or"
+	// LogicNot 唯一的子条件不满足
+	LogicNot Logic = "not"
+)
+
+// Composite 组合条件
+type Composite struct {
+	// Logic 逻辑运算
+	Logic Logic
+	// Specs 子条件
+	Specs []Specification
+}
+
+func (*Composite) isSpecification() {}
+
+// Eq 字段等于 value
+func Eq(field string, value interface{}) Specification {
+	return &Condition{Field: field, Operator: OpEq, Value: value}
+}
+
+// And 所有条件都满足，没有条件时匹配全部实体
+func And(specs ...Specification) Specification {
+	return &Composite{Logic: LogicAnd, Specs: specs}
+}
+
+// Ne 字段不等于 value
+func Ne(field string, value interface{}) Specification {
+	return &Condition{Field: field, Operator: OpNe, Value: value}
+}
+
+// Gt 字段大于 value
+func Gt(field string, value interface{}) Specification {
+	return &Condition{Field: field, Operator: OpGt, Value: value}
+}
+
+// Gte 字段大于等于 value
+func Gte(field string, value interface{}) Specification {
+	return &Condition{Field: field, Operator: OpGte, Value: value}
+}
+
+// Lt 字段小于 value
+func Lt(field string, value interface{}) Specification {
+	return &Condition{Field: field, Operator: OpLt, Value: value}
+}
+
+// Lte 字段小于等于 value
+func Lte(field string, value interface{}) Specification {
+	return &Condition{Field: field, Operator: OpLte, Value: value}
+}
+
+// Between 字段在 [from, to] 范围内
+func Between(field string, from, to interface{}) Specification {
+	return And(Gte(field, from), Lte(field, to))
+}
+
+// In 字段等于 values 中的任意一个，只传入一个切片时使用切片中的元素，没有值时不匹配任何实体
+func In(field string, values ...interface{}) Specification {
+	if len(values) == 1 {
+		if value := reflect.ValueOf(values[0]); value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8 {
+			values = make([]interface{}, value.Len())
+			for i := range values {
+				values[i] = value.Index(i).Interface()
+			}
+		}
+	}
+	return &Condition{Field: field, Operator: OpIn, Value: values}
+}
+
+// Like 字段匹配 SQL LIKE 模式，比如 Like("Name", "%foo%")，不区分大小写
+func Like(field, pattern string) Specification {
+	return &Condition{Field: field, Operator: OpLike, Value: pattern}
+}
+
+// Or 任意一个条件满足，没有条件时不匹配任何实体
+func Or(specs ...Specification) Specification {
+	return &Composite{Logic: LogicOr, Specs: specs}
+}
+
+// Not 条件不满足，字段为空导致条件不满足的实体也会匹配，比如 Not(Gt("age", 18)) 匹配 age 为空的实体
+func Not(spec Specification) Specification {
+	return &Composite{Logic: LogicNot, Specs: []Specification{spec}}
+}
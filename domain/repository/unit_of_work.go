@@ -0,0 +1,10 @@
+package repository
+
+import "context"
+
+// UnitOfWork 工作单元，保证多个仓储的写操作原子地生效
+type UnitOfWork interface {
+	// Do 在一个事务中执行 fn，fn 返回错误或者发生 panic 时回滚，否则提交。
+	// 事务保存在传给 fn 的 ctx 中，使用该 ctx 调用的仓储会自动加入事务；在 fn 中再次调用 Do 时复用外层事务
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -1,9 +1,14 @@
 package cache
 
 import (
+	"context"
 	"ddd-demo/common"
 	"ddd-demo/common/consts"
 	"ddd-demo/common/serializer"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
 	"reflect"
 	"time"
 
@@ -14,15 +19,92 @@ import (
 // BigCacheLocalCache 是基于 BigCache 的 LocalCache 实现
 type BigCacheLocalCache struct {
 	cache *bigcache.BigCache
+	// refreshGroup 未传入 singleflight.Group 时，用于合并后台刷新
+	refreshGroup singleflight.Group
+}
+
+// cacheEntry 是保存到 BigCache 中的条目，除了序列化后的结果，还记录了新鲜期等元数据
+type cacheEntry struct {
+	// Value 使用 Options.Codec 序列化后的执行结果
+	Value []byte
+	// FreshUntil 新鲜期截止时间（UnixNano），0 表示没有新鲜期限制
+	FreshUntil int64
+	// StaleUntil 允许返回旧值的截止时间（UnixNano）
+	StaleUntil int64
+	// LoadCost 执行函数的耗时（纳秒），用于计算提前过期的概率
+	LoadCost int64
+	// Err 被缓存的错误信息，不为空时表示这是一个错误结果
+	Err string
+	// NotFound 被缓存的错误是否是 consts.ErrCacheNotFound
+	NotFound bool
+}
+
+// resolve 将条目还原为执行结果，反序列化失败（包括编解码器或者 Schema 版本不一致）时 ok 为 false
+func (e *cacheEntry) resolve(codec serializer.Codec, dest interface{}) (res interface{}, ok bool, err error) {
+	if e.NotFound {
+		return nil, true, consts.ErrCacheNotFound
+	}
+	if e.Err != "" {
+		return nil, true, &CachedError{Message: e.Err}
+	}
+	if decodingErr := codec.Unmarshal(e.Value, dest); decodingErr != nil {
+		return nil, false, nil
+	}
+	return dest, true, nil
+}
+
+// isFresh 条目在 now 时刻是否新鲜
+func (e *cacheEntry) isFresh(now time.Time) bool {
+	return e.FreshUntil == 0 || now.UnixNano() < e.FreshUntil
+}
+
+// isServable 条目在 now 时刻是否仍可返回（新鲜或在允许的陈旧期内）
+func (e *cacheEntry) isServable(now time.Time) bool {
+	return e.isFresh(now) || now.UnixNano() < e.StaleUntil
+}
+
+// shouldRefreshEarly 使用 XFetch 算法判断新鲜条目是否需要提前刷新：
+// 越接近过期、加载越慢，提前刷新的概率越大
+func (e *cacheEntry) shouldRefreshEarly(now time.Time, beta float64) bool {
+	if beta <= 0 || e.FreshUntil == 0 || e.LoadCost <= 0 {
+		return false
+	}
+	gap := -float64(e.LoadCost) * beta * math.Log(1-rand.Float64())
+	return float64(now.UnixNano())+gap >= float64(e.FreshUntil)
 }
 
 // Get 先尝试从 BigCache 中读取结果，如果读到，那么反序列化，如果反序列化成功，那么返回缓存的结果。
-// 否则执行函数，如果执行成功，那么尝试将执行结果序列化，并保存到 BigCache，最后返回它
+// 否则执行函数，如果执行成功，那么尝试将执行结果序列化，并保存到 BigCache，最后返回它。
+// 通过 opts 可以设置新鲜期、过期后返回旧值并在后台刷新、概率提前刷新，以及缓存“不存在”等错误结果
 func (b *BigCacheLocalCache) Get(
 	originKey interface{},
 	f func() (interface{}, error),
 	dest interface{},
 	sfg *singleflight.Group,
+	opts ...Option,
+) (interface{}, error) {
+	return b.GetWithContext(
+		context.Background(),
+		originKey,
+		func(context.Context) (interface{}, error) {
+			return f()
+		},
+		dest,
+		sfg,
+		opts...,
+	)
+}
+
+// GetWithContext 与 Get 相同，但是等待结果的调用方可以通过 ctx 提前放弃，而函数会继续执行，供其他调用方使用。
+// 函数收到的 ctx 保留了调用方 ctx 中的值，但不会随调用方取消，可以通过 WithLoaderTimeout 为其设置超时时间。
+// 函数发生的 panic 会被转换为 consts.ErrCacheLoaderPanic 错误
+func (b *BigCacheLocalCache) GetWithContext(
+	ctx context.Context,
+	originKey interface{},
+	f func(context.Context) (interface{}, error),
+	dest interface{},
+	sfg *singleflight.Group,
+	opts ...Option,
 ) (interface{}, error) {
 	keyBuf, err := serializer.GobEncode(originKey)
 	if err != nil {
@@ -30,43 +112,135 @@ func (b *BigCacheLocalCache) Get(
 	}
 	// 生成缓存 Key
 	key := common.GetMd5(keyBuf)
+	options := newOptions(opts...)
+	if sfg == nil {
+		sfg = &b.refreshGroup
+	}
 
-	wrappedFunc := func() (interface{}, error) {
-		// 尝试从 BigCache 中读取结果
-		if buf, err := b.cache.Get(key); err == nil {
-			if decodingErr := serializer.GobDecode(dest, buf); decodingErr == nil {
-				return dest, nil
+	// 执行函数，并根据缓存策略缓存执行结果
+	load := func() (res interface{}, err error) {
+		loaderCtx, cancel := newLoaderContext(ctx, options.LoaderTimeout)
+		defer cancel()
+		defer func() {
+			if r := recover(); r != nil {
+				res, err = nil, fmt.Errorf("%w: %v", consts.ErrCacheLoaderPanic, r)
 			}
-		}
+		}()
 
-		// 执行函数
-		res, executingErr := f()
-		// 如果执行失败，则直接返回
-		if executingErr != nil {
-			return nil, executingErr
-		}
+		startTime := time.Now()
+		res, executingErr := f(loaderCtx)
+		res, storable, err := unwrapResult(res, executingErr)
 		// 如果函数返回的类型与期望的类型不一致，那么返回错误
-		if reflect.TypeOf(res) != reflect.TypeOf(dest) {
+		if err == nil && reflect.TypeOf(res) != reflect.TypeOf(dest) {
 			return nil, consts.ErrCacheResultTypeMismatched
 		}
-		// 尝试序列化，并缓存执行结果
-		if buf, err := serializer.GobEncode(res); err == nil {
-			_ = b.cache.Set(key, buf)
+		if storable {
+			b.set(key, res, executingErr, options, time.Since(startTime))
+		}
+		if err != nil {
+			return nil, err
 		}
-
 		return res, nil
 	}
 
-	if sfg != nil {
-		res, err, _ := sfg.Do(key, wrappedFunc)
-		return res, err
+	// 尝试从 BigCache 中读取结果
+	if entry, ok := b.getEntry(key); ok {
+		now := time.Now()
+		if entry.isServable(now) {
+			if res, ok, err := entry.resolve(options.Codec, dest); ok {
+				if !entry.isFresh(now) || entry.shouldRefreshEarly(now, options.EarlyRefreshBeta) {
+					b.refresh(sfg, key, load)
+				}
+				return res, err
+			}
+		}
+	}
+
+	resultChan := sfg.DoChan(key, func() (interface{}, error) {
+		// 等待期间其他调用可能已经刷新了缓存，再检查一次
+		if entry, ok := b.getEntry(key); ok && entry.isFresh(time.Now()) {
+			if res, ok, err := entry.resolve(options.Codec, dest); ok {
+				return res, err
+			}
+		}
+		return load()
+	})
+
+	var timeout <-chan time.Time
+	if options.LoaderTimeout > 0 {
+		timer := time.NewTimer(options.LoaderTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case result := <-resultChan:
+		return result.Val, result.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		return nil, consts.ErrCacheLoaderTimeout
+	}
+}
+
+// refresh 在后台执行 load，相同 Key 的刷新以及同步加载通过 sfg 合并
+func (b *BigCacheLocalCache) refresh(sfg *singleflight.Group, key string, load func() (interface{}, error)) {
+	go func() {
+		_, _, _ = sfg.Do(key, load)
+	}()
+}
+
+// getEntry 从 BigCache 中读取条目
+func (b *BigCacheLocalCache) getEntry(key string) (*cacheEntry, bool) {
+	buf, err := b.cache.Get(key)
+	if err != nil {
+		return nil, false
 	}
+	entry := &cacheEntry{}
+	if err := serializer.GobDecode(entry, buf); err != nil {
+		return nil, false
+	}
+	return entry, true
+}
 
-	return wrappedFunc()
+// set 根据缓存策略，将执行结果或错误序列化后保存到 BigCache，序列化失败时不缓存
+func (b *BigCacheLocalCache) set(key string, res interface{}, err error, options *Options, loadCost time.Duration) {
+	decision := options.Policy(res, err)
+	entry := &cacheEntry{LoadCost: int64(loadCost)}
+	switch decision.Action {
+	case StoreValue:
+		if err != nil {
+			return
+		}
+		value, encodingErr := options.Codec.Marshal(res)
+		if encodingErr != nil {
+			return
+		}
+		entry.Value = value
+	case StoreError:
+		if err == nil {
+			return
+		}
+		entry.Err = err.Error()
+		entry.NotFound = errors.Is(err, consts.ErrCacheNotFound)
+	default:
+		return
+	}
+	if decision.TTL > 0 {
+		freshUntil := time.Now().Add(decision.TTL)
+		entry.FreshUntil = freshUntil.UnixNano()
+		// 只有正常的执行结果才允许在过期后继续返回
+		if decision.Action == StoreValue && options.MaxStale > 0 {
+			entry.StaleUntil = freshUntil.Add(options.MaxStale).UnixNano()
+		}
+	}
+	if buf, encodingErr := serializer.GobEncode(entry); encodingErr == nil {
+		_ = b.cache.Set(key, buf)
+	}
 }
 
 const (
-	// BigCacheLifeWindowMS 时长后，缓存条目可被踢除，默认值是 10000 毫秒
+	// BigCacheLifeWindowMS 时长后，缓存条目可被踢除，默认值是 10000 毫秒。
+	// 使用 WithTTL 等选项时，新鲜期与陈旧期之和应小于该值，否则条目会先被踢除
 	BigCacheLifeWindowMS = 10000
 	// BigCacheShards shard 的数量，其值必须是 2 的乘方，默认值为 2
 	BigCacheShards = 2
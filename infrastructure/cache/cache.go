@@ -1,9 +1,17 @@
 package cache
 
-import "golang.org/x/sync/singleflight"
+import (
+	"context"
+
+	"golang.org/x/sync/singleflight"
+)
 
 // LocalCache 本地缓存比如进程内内存缓存
 type LocalCache interface {
 	// Get 用于从缓存中获取函数的执行结果，如果未获取到，那么执行函数，并将结果保存到缓存
-	Get(key interface{}, f func() (interface{}, error), dest interface{}, sfg *singleflight.Group) (interface{}, error)
+	Get(key interface{}, f func() (interface{}, error), dest interface{}, sfg *singleflight.Group,
+		opts ...Option) (interface{}, error)
+	// GetWithContext 与 Get 相同，但是调用方可以通过 ctx 放弃等待，函数也会收到 ctx
+	GetWithContext(ctx context.Context, key interface{}, f func(context.Context) (interface{}, error), dest interface{},
+		sfg *singleflight.Group, opts ...Option) (interface{}, error)
 }
@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// detachedContext 保留父 ctx 中的值，但不继承其截止时间与取消信号，
+// 这样某个调用方放弃等待时，被合并执行的函数不会被一同取消
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// newLoaderContext 创建传给函数的 ctx，timeout 大于 0 时为其设置超时时间
+func newLoaderContext(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx := context.Context(detachedContext{parent: parent})
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"ddd-demo/common/serializer"
+	"time"
+)
+
+// DefaultCodec 默认使用 gob 序列化执行结果
+var DefaultCodec serializer.Codec = serializer.NewVersionedCodec(serializer.GobCodec{}, 0)
+
+// Options 控制 LocalCache.Get 的缓存策略
+type Options struct {
+	// TTL 缓存结果的新鲜期，小于等于 0 表示只要缓存中存在就认为是新鲜的
+	TTL time.Duration
+	// MaxStale 新鲜期结束后，仍可返回旧值并在后台刷新的最长时间，小于等于 0 表示不返回旧值
+	MaxStale time.Duration
+	// EarlyRefreshBeta 概率提前过期（XFetch）的系数，越大越倾向于提前刷新，小于等于 0 表示不提前刷新
+	EarlyRefreshBeta float64
+	// NegativeTTL 缓存 consts.ErrCacheNotFound 等错误结果的新鲜期，通常比 TTL 短，小于等于 0 表示不缓存“不存在”的结果
+	NegativeTTL time.Duration
+	// Policy 缓存策略，为 nil 时使用 DefaultPolicy
+	Policy Policy
+	// Codec 执行结果的编解码器，为 nil 时使用 DefaultCodec。
+	// 建议使用 serializer.VersionedCodec，这样编解码器或者 Schema 版本变化后，旧的缓存条目会被忽略
+	Codec serializer.Codec
+	// LoaderTimeout 函数的超时时间，同时也是调用方等待的最长时间，小于等于 0 表示不限制
+	LoaderTimeout time.Duration
+}
+
+// Option 用于设置 Options
+type Option func(*Options)
+
+// WithTTL 设置缓存结果的新鲜期
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// WithStaleWhileRevalidate 新鲜期结束后的 maxStale 时长内，直接返回旧值，同时在后台刷新
+func WithStaleWhileRevalidate(maxStale time.Duration) Option {
+	return func(o *Options) {
+		o.MaxStale = maxStale
+	}
+}
+
+// WithEarlyRefresh 在新鲜期结束前按概率提前在后台刷新，避免热点 Key 同时过期引起的击穿，通常取 1.0
+func WithEarlyRefresh(beta float64) Option {
+	return func(o *Options) {
+		o.EarlyRefreshBeta = beta
+	}
+}
+
+// WithNegativeTTL 设置“不存在”等错误结果的缓存时长
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.NegativeTTL = ttl
+	}
+}
+
+// WithPolicy 设置缓存策略，由它根据每次的执行结果决定是否缓存以及缓存多久
+func WithPolicy(policy Policy) Option {
+	return func(o *Options) {
+		o.Policy = policy
+	}
+}
+
+// WithCodec 设置执行结果的编解码器
+func WithCodec(codec serializer.Codec) Option {
+	return func(o *Options) {
+		o.Codec = codec
+	}
+}
+
+// WithLoaderTimeout 设置函数的超时时间
+func WithLoaderTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.LoaderTimeout = timeout
+	}
+}
+
+// newOptions 根据 Option 列表生成 Options
+func newOptions(opts ...Option) *Options {
+	o := &Options{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	if o.Policy == nil {
+		o.Policy = DefaultPolicy(o)
+	}
+	if o.Codec == nil {
+		o.Codec = DefaultCodec
+	}
+	return o
+}
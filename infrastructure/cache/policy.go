@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"ddd-demo/common/consts"
+	"errors"
+	"time"
+)
+
+// StoreAction 表示如何处理函数的执行结果
+type StoreAction int
+
+const (
+	// StoreSkip 不缓存
+	StoreSkip StoreAction = iota
+	// StoreValue 缓存执行结果
+	StoreValue
+	// StoreError 缓存执行错误，在有效期内直接返回该错误，不再执行函数
+	StoreError
+)
+
+// Decision 是缓存策略对一次执行结果的处理决定
+type Decision struct {
+	// Action 处理方式
+	Action StoreAction
+	// TTL 缓存的新鲜期，小于等于 0 表示只要缓存中存在就认为是新鲜的
+	TTL time.Duration
+}
+
+// Policy 缓存策略，根据函数的执行结果决定是否缓存以及缓存多久
+type Policy func(res interface{}, err error) Decision
+
+// DefaultPolicy 默认的缓存策略：
+// 执行成功时按 TTL 缓存结果；
+// 返回 consts.ErrCacheNotFound 时，如果设置了 NegativeTTL，那么按 NegativeTTL 缓存该错误；
+// 返回 CacheError 包装的错误时，按 NegativeTTL（未设置时使用 TTL）缓存该错误；
+// 其他错误不缓存
+func DefaultPolicy(options *Options) Policy {
+	return func(res interface{}, err error) Decision {
+		if err == nil {
+			return Decision{Action: StoreValue, TTL: options.TTL}
+		}
+		var cacheable *cacheableError
+		if errors.As(err, &cacheable) {
+			ttl := options.NegativeTTL
+			if ttl <= 0 {
+				ttl = options.TTL
+			}
+			return Decision{Action: StoreError, TTL: ttl}
+		}
+		if errors.Is(err, consts.ErrCacheNotFound) && options.NegativeTTL > 0 {
+			return Decision{Action: StoreError, TTL: options.NegativeTTL}
+		}
+		return Decision{Action: StoreSkip}
+	}
+}
+
+// noStoreResult 包装不需要缓存的执行结果
+type noStoreResult struct {
+	res interface{}
+}
+
+// DoNotCache 包装函数的执行结果，被包装的结果会正常返回给调用方，但不会被缓存
+func DoNotCache(res interface{}) interface{} {
+	return &noStoreResult{res: res}
+}
+
+// cacheableError 包装需要缓存的错误
+type cacheableError struct {
+	err error
+}
+
+func (e *cacheableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *cacheableError) Unwrap() error {
+	return e.err
+}
+
+// CacheError 包装函数返回的错误，被包装的错误会被缓存
+func CacheError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &cacheableError{err: err}
+}
+
+// CachedError 是从缓存中恢复的错误，只保留了原始错误的信息
+type CachedError struct {
+	Message string
+}
+
+func (e *CachedError) Error() string {
+	return e.Message
+}
+
+// unwrapResult 拆开 DoNotCache 与 CacheError 的包装，返回原始的结果、结果是否允许缓存以及原始的错误
+func unwrapResult(res interface{}, err error) (interface{}, bool, error) {
+	storable := true
+	if r, ok := res.(*noStoreResult); ok {
+		res = r.res
+		storable = false
+	}
+	// 函数可能在 CacheError 外面再包装一层，此时保留外层的信息，CacheError 的包装对 Error 以及 errors.Is 透明
+	var cacheable *cacheableError
+	if errors.As(err, &cacheable) && err == error(cacheable) {
+		err = cacheable.err
+	}
+	return res, storable, err
+}
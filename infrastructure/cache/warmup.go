@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"context"
+	"ddd-demo/common/consts"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultWarmupConcurrency 默认的预热并发数
+const DefaultWarmupConcurrency = 8
+
+// WarmupTask 缓存预热任务，Key、函数以及 Options 需要与业务代码调用 LocalCache 时保持一致，否则预热的条目不会被命中
+type WarmupTask struct {
+	// Name 任务名称
+	Name string
+	// Keys 需要预热的 Key 列表
+	Keys []interface{}
+	// KeysFunc 用于查询需要预热的 Key 列表，比如查询最近活跃的记录，结果会追加到 Keys 之后
+	KeysFunc func(ctx context.Context) ([]interface{}, error)
+	// Loader 根据 Key 加载数据
+	Loader func(ctx context.Context, key interface{}) (interface{}, error)
+	// NewDest 为每个 Key 创建用于反序列化的对象
+	NewDest func() interface{}
+	// Options 调用 LocalCache 时使用的选项
+	Options []Option
+}
+
+// Warmer 缓存预热器，在服务开始接收流量之前，将注册的任务加载到 LocalCache 中
+type Warmer struct {
+	cache       LocalCache
+	sfg         *singleflight.Group
+	concurrency int
+
+	mu    sync.Mutex
+	tasks []*WarmupTask
+	// done 预热是否已经完成，1 表示完成
+	done int32
+}
+
+// NewWarmer 创建缓存预热器，concurrency 小于等于 0 时使用 DefaultWarmupConcurrency
+func NewWarmer(cache LocalCache, sfg *singleflight.Group, concurrency int) *Warmer {
+	if concurrency <= 0 {
+		concurrency = DefaultWarmupConcurrency
+	}
+	return &Warmer{
+		cache:       cache,
+		sfg:         sfg,
+		concurrency: concurrency,
+	}
+}
+
+// Register 注册预热任务
+func (w *Warmer) Register(tasks ...*WarmupTask) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, task := range tasks {
+		if task != nil && task.Loader != nil && task.NewDest != nil {
+			w.tasks = append(w.tasks, task)
+		}
+	}
+}
+
+// Ready 预热是否已经完成
+func (w *Warmer) Ready() bool {
+	return atomic.LoadInt32(&w.done) == 1
+}
+
+// Check 用于就绪检查，预热未完成时返回 consts.ErrCacheWarmupPending
+func (w *Warmer) Check() error {
+	if !w.Ready() {
+		return consts.ErrCacheWarmupPending
+	}
+	return nil
+}
+
+// warmupJob 单个 Key 的预热
+type warmupJob struct {
+	task *WarmupTask
+	key  interface{}
+}
+
+// Warmup 执行所有预热任务，最多同时加载 concurrency 个 Key。
+// 单个 Key 加载失败不会中断预热，返回的错误汇总了失败的数量与第一个错误。无论成功与否，结束后 Ready 都会返回 true
+func (w *Warmer) Warmup(ctx context.Context) error {
+	defer atomic.StoreInt32(&w.done, 1)
+
+	w.mu.Lock()
+	tasks := make([]*WarmupTask, len(w.tasks))
+	copy(tasks, w.tasks)
+	w.mu.Unlock()
+
+	var (
+		errMu    sync.Mutex
+		firstErr error
+		failed   int
+		total    int
+	)
+	addErr := func(err error) {
+		errMu.Lock()
+		defer errMu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		failed++
+	}
+
+	jobs := make(chan *warmupJob)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := w.warmupKey(ctx, job); err != nil {
+					addErr(fmt.Errorf("task %s key %v: %w", job.task.Name, job.key, err))
+				}
+			}
+		}()
+	}
+
+	for _, task := range tasks {
+		keys := task.Keys
+		if task.KeysFunc != nil {
+			queriedKeys, err := task.KeysFunc(ctx)
+			if err != nil {
+				addErr(fmt.Errorf("task %s: %w", task.Name, err))
+			}
+			keys = append(append([]interface{}{}, keys...), queriedKeys...)
+		}
+		for _, key := range keys {
+			if ctx.Err() != nil {
+				break
+			}
+			total++
+			jobs <- &warmupJob{task: task, key: key}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if firstErr != nil {
+		return fmt.Errorf("cache warmup: %d of %d keys failed, first error: %w", failed, total, firstErr)
+	}
+	return nil
+}
+
+// warmupKey 加载单个 Key 并写入缓存
+func (w *Warmer) warmupKey(ctx context.Context, job *warmupJob) error {
+	_, err := w.cache.GetWithContext(
+		ctx,
+		job.key,
+		func(ctx context.Context) (interface{}, error) {
+			return job.task.Loader(ctx, job.key)
+		},
+		job.task.NewDest(),
+		w.sfg,
+		job.task.Options...,
+	)
+	// 不存在的记录不算预热失败
+	if errors.Is(err, consts.ErrCacheNotFound) {
+		return nil
+	}
+	return err
+}
@@ -2,6 +2,10 @@ package config
 
 // Configuration 服务配置需要实现的接口
 type Configuration interface {
+	// Get 获取任意类型配置
+	Get(key string) interface{}
+	// AllKeys 获取所有配置的 Key
+	AllKeys() []string
 	// GetBool 获取 bool 类型配置
 	GetBool(key string) bool
 	// GetInt 获取 int 类型配置
@@ -18,4 +22,12 @@ type Configuration interface {
 	GetStringMapString(key string) map[string]string
 	// GetStringMapStringSlice 获取 map 类型配置
 	GetStringMapStringSlice(key string) map[string][]string
+	// Source 获取配置的来源层，比如 default、file、env、flag
+	Source(key string) string
+	// Profile 获取当前激活的 Profile，比如 dev、test、prod
+	Profile() string
+	// Watch 订阅 Key 的变化，配置重新加载后 Key 的值发生变化时调用 fn
+	Watch(key string, fn func(oldValue, newValue interface{}))
+	// Unmarshal 将 key 对应的配置节绑定到结构体，支持 default tag 默认值以及 validate tag 校验
+	Unmarshal(key string, out interface{}) error
 }
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"ddd-demo/common/consts"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// SupportedConfigTypes 支持的配置文件格式，格式由扩展名决定。搜索配置文件时按该顺序尝试扩展名
+var SupportedConfigTypes = []string{"yaml", "yml", "json", "toml"}
+
+// configType 获取配置文件的格式，不支持的扩展名返回 consts.ErrConfigUnsupportedType
+func configType(file string) (string, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(file), "."))
+	for _, supported := range SupportedConfigTypes {
+		if ext == supported {
+			return ext, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s, supported: %s", consts.ErrConfigUnsupportedType, file,
+		strings.Join(SupportedConfigTypes, ", "))
+}
+
+// findConfigFile 在 paths 中依次查找名为 name、扩展名为 SupportedConfigTypes 之一的配置文件，返回第一个找到的文件。
+// 找不到时返回的错误列出了所有搜索过的路径
+func findConfigFile(name string, paths []string) (string, error) {
+	var searched []string
+	for _, configPath := range paths {
+		for _, ext := range SupportedConfigTypes {
+			file := filepath.Join(configPath, name+"."+ext)
+			if info, err := os.Stat(file); err == nil && !info.IsDir() {
+				return file, nil
+			}
+		}
+		searched = append(searched, configPath)
+	}
+	return "", fmt.Errorf("%w: %s.{%s} in [%s]", consts.ErrConfigFileNotFound, name,
+		strings.Join(SupportedConfigTypes, ","), strings.Join(searched, ", "))
+}
+
+// resolveConfigFiles 获取需要加载的配置文件，按顺序合并，后面的覆盖前面的。
+// 优先使用 Options.ConfigFiles，其次使用 --config 参数或者 <EnvPrefix>_CONFIG 环境变量（多个文件以 , 分隔），
+// 都为空时在 Options.ConfigPaths 中搜索 Options.ConfigName。指定的路径是目录时，在该目录下搜索 Options.ConfigName
+func resolveConfigFiles(options *Options, cmd *commandLine) ([]string, error) {
+	files := options.ConfigFiles
+	if len(files) == 0 {
+		if value := resolveControl(cmd, FlagConfig, options.EnvPrefix, EnvConfig); value != "" {
+			for _, file := range strings.Split(value, ",") {
+				if file = strings.TrimSpace(file); file != "" {
+					files = append(files, file)
+				}
+			}
+		}
+	}
+	if len(files) == 0 {
+		file, err := findConfigFile(options.ConfigName, options.ConfigPaths)
+		if err != nil {
+			return nil, err
+		}
+		return []string{file}, nil
+	}
+
+	resolved := make([]string, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", consts.ErrConfigFileNotFound, file)
+		}
+		if info.IsDir() {
+			if file, err = findConfigFile(options.ConfigName, []string{file}); err != nil {
+				return nil, err
+			}
+		} else if _, err := configType(file); err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, file)
+	}
+	return resolved, nil
+}
+
+// profileSearchPaths Profile 配置文件的搜索路径：配置文件所在的目录以及 Options.ConfigPaths
+func profileSearchPaths(options *Options, files []string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, configPath := range append(configDirs(files), options.ConfigPaths...) {
+		if !seen[configPath] {
+			seen[configPath] = true
+			paths = append(paths, configPath)
+		}
+	}
+	return paths
+}
+
+// configDirs 获取配置文件所在的目录
+func configDirs(files []string) []string {
+	dirs := make([]string, 0, len(files))
+	for _, file := range files {
+		dirs = append(dirs, filepath.Dir(file))
+	}
+	return dirs
+}
+
+// readConfigFiles 按顺序读取配置文件，后面的文件深度合并到前面的文件之上，嵌套的 map 会逐个 Key 合并
+func readConfigFiles(v *viper.Viper, files []string) error {
+	for i, file := range files {
+		v.SetConfigFile(file)
+		read := v.MergeInConfig
+		if i == 0 {
+			read = v.ReadInConfig
+		}
+		if err := read(); err != nil {
+			return fmt.Errorf("read config file %s: %w", file, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"ddd-demo/common/consts"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 配置的来源层，优先级从低到高依次为：默认值、配置文件、远程配置中心、环境变量、命令行参数
+const (
+	// SourceNone 未配置
+	SourceNone = ""
+	// SourceDefault 默认值
+	SourceDefault = "default"
+	// SourceFile 配置文件
+	SourceFile = "file"
+	// SourceRemote 远程配置中心，比如 etcd、Consul 的键值存储
+	SourceRemote = "remote"
+	// SourceEnv 环境变量，比如 DDD_SERVER_PORT 对应 server.port
+	SourceEnv = "env"
+	// SourceFlag 命令行参数，比如 --server.port=9090
+	SourceFlag = "flag"
+)
+
+// envReplacer 将配置 Key 转换为环境变量名时使用的替换规则
+var envReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// envName 返回配置 Key 对应的环境变量名，比如 server.port 对应 DDD_SERVER_PORT。
+// 由于配置 Key 不区分大小写，server.logWithBody 对应 DDD_SERVER_LOGWITHBODY
+func envName(prefix, key string) string {
+	name := strings.ToUpper(envReplacer.Replace(key))
+	if prefix == "" {
+		return name
+	}
+	return strings.ToUpper(prefix) + "_" + name
+}
+
+// lookupEnv 查找配置 Key 对应的环境变量
+func lookupEnv(prefix, key string) (string, bool) {
+	return os.LookupEnv(envName(prefix, key))
+}
+
+// 命令行中用于控制配置加载的参数名
+const (
+	// FlagProfile 选择 Profile，比如 --profile=dev
+	FlagProfile = "profile"
+	// FlagConfig 指定配置文件或者配置目录，多个以 , 分隔，比如 --config=config.yaml,secrets.yaml
+	FlagConfig = "config"
+	// FlagRemoteConfigDir 作为远程配置中心的目录，比如 --remote-config-dir=/etc/ddd-demo
+	FlagRemoteConfigDir = "remote-config-dir"
+)
+
+// 控制配置加载的环境变量名，会加上 EnvPrefix 前缀
+const (
+	// EnvProfile 选择 Profile，比如 DDD_PROFILE=dev
+	EnvProfile = "PROFILE"
+	// EnvConfig 指定配置文件或者配置目录，多个以 , 分隔，比如 DDD_CONFIG=/etc/ddd-demo/config.toml
+	EnvConfig = "CONFIG"
+	// EnvRemoteConfigDir 作为远程配置中心的目录，比如 DDD_REMOTE_CONFIG_DIR=/etc/ddd-demo
+	EnvRemoteConfigDir = "REMOTE_CONFIG_DIR"
+)
+
+// commandLine 命令行参数的解析结果
+type commandLine struct {
+	// overrides 覆盖配置的参数，Key 为小写
+	overrides map[string]string
+	// controls 控制配置加载的参数，比如 profile
+	controls map[string]string
+}
+
+// isControlFlag 是否是控制配置加载的参数名
+func isControlFlag(name string) bool {
+	return name == FlagProfile || name == FlagConfig || name == FlagRemoteConfigDir
+}
+
+// parseFlags 解析命令行参数，支持 --key=value 与 --key value 两种形式。
+// 包含 . 的参数名（比如 --server.port）会被当作配置 Key，--profile 等参数用于控制配置的加载，其他参数以及位置参数会被忽略
+func parseFlags(args []string) (*commandLine, error) {
+	cmd := &commandLine{
+		overrides: make(map[string]string),
+		controls:  make(map[string]string),
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		name, value := strings.TrimPrefix(arg, "--"), ""
+		hasValue := false
+		if index := strings.Index(name, "="); index >= 0 {
+			name, value, hasValue = name[:index], name[index+1:], true
+		}
+		if !strings.Contains(name, ".") && !isControlFlag(name) {
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) || strings.HasPrefix(args[i+1], "--") {
+				return nil, fmt.Errorf("%w: %s", consts.ErrConfigFlagWithoutValue, arg)
+			}
+			i++
+			value = args[i]
+		}
+		if isControlFlag(name) {
+			cmd.controls[name] = value
+		} else {
+			cmd.overrides[strings.ToLower(name)] = value
+		}
+	}
+	return cmd, nil
+}
+
+// resolveControl 获取控制配置加载的参数，优先使用命令行参数，其次使用环境变量
+func resolveControl(cmd *commandLine, flagName, envPrefix, envName string) string {
+	if value, found := cmd.controls[flagName]; found {
+		return value
+	}
+	if envPrefix == "" {
+		return ""
+	}
+	return os.Getenv(strings.ToUpper(envPrefix) + "_" + envName)
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"context"
+	"ddd-demo/common/consts"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRemotePollInterval 默认的远程配置轮询间隔，也是监听出错后的重试间隔
+	DefaultRemotePollInterval = 30 * time.Second
+	// DefaultRemoteTimeout 默认的读取远程配置的超时时间
+	DefaultRemoteTimeout = 5 * time.Second
+)
+
+// RemoteSource 远程键值配置中心的抽象，比如 etcd、Consul 的键值存储
+type RemoteSource interface {
+	// Name 配置源的名称，用于日志与错误信息
+	Name() string
+	// Load 读取所有配置
+	Load(ctx context.Context) (*RemoteSnapshot, error)
+	// Watch 长轮询，阻塞直到配置的版本与 version 不同或者 ctx 结束，返回新的配置。
+	// 不支持长轮询时返回 consts.ErrConfigWatchUnsupported，此时按 Options.RemotePollInterval 定时调用 Load
+	Watch(ctx context.Context, version string) (*RemoteSnapshot, error)
+}
+
+// RemoteSnapshot 一次读取得到的远程配置
+type RemoteSnapshot struct {
+	// Values 配置项，Key 使用 . 分隔的完整路径，比如 server.port，值在读取时按需转换类型
+	Values map[string]string `json:"values"`
+	// Version 配置的版本，比如 Consul 的 X-Consul-Index、etcd 的 revision，配置不变时版本不变
+	Version string `json:"version"`
+}
+
+// normalize 将配置项的 Key 转换为小写，与 viper 的 Key 保持一致，以便按 Key 查找来源层
+func (s *RemoteSnapshot) normalize() *RemoteSnapshot {
+	values := make(map[string]string, len(s.Values))
+	for key, value := range s.Values {
+		values[strings.ToLower(key)] = value
+	}
+	s.Values = values
+	return s
+}
+
+// nested 将配置项转换为嵌套的 map，以便合并到 viper 中
+func (s *RemoteSnapshot) nested() map[string]interface{} {
+	settings := make(map[string]interface{})
+	for key, value := range s.Values {
+		path := strings.Split(strings.ToLower(key), ".")
+		node := settings
+		for _, name := range path[:len(path)-1] {
+			child, ok := node[name].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[name] = child
+			}
+			node = child
+		}
+		node[path[len(path)-1]] = value
+	}
+	return settings
+}
+
+// loadRemote 读取远程配置，成功时写入本地缓存文件。
+// 配置中心不可用时使用本地缓存文件中最近一次读取到的配置，保证服务仍然可以启动
+func loadRemote(options *Options) (*RemoteSnapshot, error) {
+	timeout := options.RemoteTimeout
+	if timeout <= 0 {
+		timeout = DefaultRemoteTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	snapshot, err := options.Remote.Load(ctx)
+	if err == nil {
+		if cacheErr := writeRemoteCache(options.RemoteCacheFile, snapshot); cacheErr != nil {
+			fmt.Println("write remote config cache error:", cacheErr)
+		}
+		return snapshot.normalize(), nil
+	}
+	cached, cacheErr := readRemoteCache(options.RemoteCacheFile)
+	if cacheErr != nil {
+		return nil, fmt.Errorf("%w: %s: %v", consts.ErrConfigRemoteUnavailable, options.Remote.Name(), err)
+	}
+	fmt.Printf("load remote config from %s error, use local cache %s: %v\n",
+		options.Remote.Name(), options.RemoteCacheFile, err)
+	return cached.normalize(), nil
+}
+
+// writeRemoteCache 将远程配置写入本地缓存文件，缓存中包含未脱敏的配置，只有当前用户可以读写。
+// 先写随机命名的临时文件再重命名，避免写入中途失败时损坏缓存
+func writeRemoteCache(file string, snapshot *RemoteSnapshot) error {
+	if file == "" {
+		return nil
+	}
+	buf, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// readRemoteCache 读取本地缓存的远程配置
+func readRemoteCache(file string) (*RemoteSnapshot, error) {
+	if file == "" {
+		return nil, os.ErrNotExist
+	}
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &RemoteSnapshot{}
+	if err := json.Unmarshal(buf, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// remoteWatcher 监听远程配置的变化，优先使用长轮询，不支持时定时轮询
+type remoteWatcher struct {
+	source   RemoteSource
+	interval time.Duration
+	onChange func()
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// newRemoteWatcher 创建远程配置监听器，从 version 开始监听，配置变化后调用 onChange
+func newRemoteWatcher(source RemoteSource, interval time.Duration, version string, onChange func()) *remoteWatcher {
+	if interval == 0 {
+		interval = DefaultRemotePollInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &remoteWatcher{
+		source:   source,
+		interval: interval,
+		onChange: onChange,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go w.run(ctx, version)
+	return w
+}
+
+// run 循环监听，直到 ctx 结束
+func (w *remoteWatcher) run(ctx context.Context, version string) {
+	defer close(w.done)
+	longPoll := true
+	for {
+		var (
+			snapshot *RemoteSnapshot
+			err      error
+		)
+		if longPoll {
+			snapshot, err = w.source.Watch(ctx, version)
+			if errors.Is(err, consts.ErrConfigWatchUnsupported) {
+				longPoll = false
+				continue
+			}
+		} else {
+			if !w.sleep(ctx) {
+				return
+			}
+			snapshot, err = w.source.Load(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Printf("watch remote config from %s error: %v\n", w.source.Name(), err)
+			// 长轮询出错后等待一个轮询间隔再重试，避免配置中心故障时频繁请求
+			if longPoll && !w.sleep(ctx) {
+				return
+			}
+			continue
+		}
+		if snapshot.Version != version {
+			// 无论重新加载是否成功都记录新版本，避免校验失败的配置被反复加载
+			version = snapshot.Version
+			w.onChange()
+		}
+	}
+}
+
+// sleep 等待一个轮询间隔，ctx 结束时返回 false
+func (w *remoteWatcher) sleep(ctx context.Context) bool {
+	timer := time.NewTimer(w.interval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// close 停止监听，并等待监听协程退出
+func (w *remoteWatcher) close() {
+	w.cancel()
+	<-w.done
+}
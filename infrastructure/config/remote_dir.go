@@ -0,0 +1,119 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultDirectoryPollInterval DirectorySource 长轮询时检查目录变化的默认间隔
+const DefaultDirectoryPollInterval = time.Second
+
+// DirectorySource 基于目录的 RemoteSource 实现，用于在没有 etcd、Consul 等外部服务时开发与测试。
+// 目录下的每个文件是一个配置项，相对路径为 Key，文件内容（去掉首尾空白）为值，比如 server/port 文件对应 server.port。
+// 与 Kubernetes 挂载 ConfigMap 的目录兼容，以 . 开头的文件与目录会被忽略
+type DirectorySource struct {
+	// Dir 配置目录
+	Dir string
+	// PollInterval 长轮询时检查目录变化的间隔，为 0 时使用 DefaultDirectoryPollInterval
+	PollInterval time.Duration
+}
+
+// NewDirectorySource 创建基于目录的 RemoteSource
+func NewDirectorySource(dir string) *DirectorySource {
+	return &DirectorySource{Dir: dir}
+}
+
+// Name 配置源的名称
+func (d *DirectorySource) Name() string {
+	return "dir:" + d.Dir
+}
+
+// Load 读取目录下的所有配置项，版本为所有配置项的摘要
+func (d *DirectorySource) Load(ctx context.Context) (*RemoteSnapshot, error) {
+	values := make(map[string]string)
+	err := filepath.Walk(d.Dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if file == d.Dir {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// ConfigMap 中的配置项是指向 ..data 目录的符号链接
+		if info.Mode()&os.ModeSymlink != 0 {
+			if info, err = os.Stat(file); err != nil {
+				return err
+			}
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(d.Dir, file)
+		if err != nil {
+			return err
+		}
+		values[strings.ReplaceAll(filepath.ToSlash(rel), "/", ".")] = strings.TrimSpace(string(content))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteSnapshot{Values: values, Version: digest(values)}, nil
+}
+
+// Watch 定时读取目录，直到版本与 version 不同或者 ctx 结束
+func (d *DirectorySource) Watch(ctx context.Context, version string) (*RemoteSnapshot, error) {
+	interval := d.PollInterval
+	if interval <= 0 {
+		interval = DefaultDirectoryPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		snapshot, err := d.Load(ctx)
+		if err != nil || snapshot.Version != version {
+			return snapshot, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// digest 计算配置项的摘要
+func digest(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(values[key]))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"ddd-demo/common/consts"
+	"ddd-demo/common/encrypt"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// 加密配置值的格式为 ENC(<base64 密文>)
+const (
+	secretPrefix = "ENC("
+	secretSuffix = ")"
+)
+
+// 读取配置密钥的环境变量名，会加上 EnvPrefix 前缀
+const (
+	// EnvSecretKey 配置密钥，长度必须为 16、24 或 32 字节，比如 DDD_CONFIG_KEY
+	EnvSecretKey = "CONFIG_KEY"
+	// EnvSecretKeyFile 配置密钥文件的路径，比如 DDD_CONFIG_KEY_FILE
+	EnvSecretKeyFile = "CONFIG_KEY_FILE"
+)
+
+// isSecret 是否是 ENC(...) 形式的加密值
+func isSecret(value string) bool {
+	return strings.HasPrefix(value, secretPrefix) && strings.HasSuffix(value, secretSuffix)
+}
+
+// EncryptSecret 使用 AES 加密明文，返回可以写入配置文件的 ENC(...) 形式的值
+func EncryptSecret(key []byte, plaintext string) (string, error) {
+	crypted, err := encrypt.NewAES(key).Encrypt([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.StdEncoding.EncodeToString(crypted) + secretSuffix, nil
+}
+
+// DecryptSecret 解密 ENC(...) 形式的值
+func DecryptSecret(key []byte, value string) (string, error) {
+	if !isSecret(value) {
+		return value, nil
+	}
+	crypted, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(value, secretPrefix), secretSuffix))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", consts.ErrConfigSecretDecrypt, err)
+	}
+	plaintext, err := encrypt.NewAES(key).Decrypt(crypted)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", consts.ErrConfigSecretDecrypt, err)
+	}
+	return string(plaintext), nil
+}
+
+// LoadSecretKey 依次从 <envPrefix>_CONFIG_KEY 环境变量以及 <envPrefix>_CONFIG_KEY_FILE 指定的文件中读取配置密钥，
+// 都未设置时返回 nil
+func LoadSecretKey(envPrefix string) ([]byte, error) {
+	prefix := ""
+	if envPrefix != "" {
+		prefix = strings.ToUpper(envPrefix) + "_"
+	}
+	if key := os.Getenv(prefix + EnvSecretKey); key != "" {
+		return []byte(key), nil
+	}
+	if keyFile := os.Getenv(prefix + EnvSecretKeyFile); keyFile != "" {
+		key, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimSpace(key), nil
+	}
+	return nil, nil
+}
+
+// secretResolver 解密配置中 ENC(...) 形式的值，加载配置时预先解密，读取时直接使用明文
+type secretResolver struct {
+	key []byte
+	// plaintexts 密文到明文的映射
+	plaintexts map[string]string
+}
+
+// collect 解密 value 中所有的加密值，value 可以是嵌套的 map 或者切片
+func (r *secretResolver) collect(key string, value interface{}) error {
+	switch typed := value.(type) {
+	case string:
+		if !isSecret(typed) {
+			return nil
+		}
+		if _, found := r.plaintexts[typed]; found {
+			return nil
+		}
+		if len(r.key) == 0 {
+			return fmt.Errorf("%s: %w", key, consts.ErrConfigSecretKeyMissing)
+		}
+		plaintext, err := DecryptSecret(r.key, typed)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		r.plaintexts[typed] = plaintext
+	case []interface{}:
+		for _, item := range typed {
+			if err := r.collect(key, item); err != nil {
+				return err
+			}
+		}
+	case []string:
+		for _, item := range typed {
+			if err := r.collect(key, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for name, item := range typed {
+			if err := r.collect(key+"."+name, item); err != nil {
+				return err
+			}
+		}
+	case map[string]string:
+		for name, item := range typed {
+			if err := r.collect(key+"."+name, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve 将 value 中的加密值替换为明文，未能解密的值保持不变
+func (r *secretResolver) resolve(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case string:
+		if plaintext, found := r.plaintexts[typed]; found {
+			return plaintext
+		}
+		if isSecret(typed) && len(r.key) > 0 {
+			if plaintext, err := DecryptSecret(r.key, typed); err == nil {
+				return plaintext
+			}
+		}
+		return typed
+	case []interface{}:
+		resolved := make([]interface{}, len(typed))
+		for i, item := range typed {
+			resolved[i] = r.resolve(item)
+		}
+		return resolved
+	case []string:
+		resolved := make([]string, len(typed))
+		for i, item := range typed {
+			resolved[i] = r.resolve(item).(string)
+		}
+		return resolved
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(typed))
+		for name, item := range typed {
+			resolved[name] = r.resolve(item)
+		}
+		return resolved
+	case map[string]string:
+		resolved := make(map[string]string, len(typed))
+		for name, item := range typed {
+			resolved[name] = r.resolve(item).(string)
+		}
+		return resolved
+	}
+	return value
+}
+
+// RunEncryptCommand 实现 encrypt 子命令：使用配置密钥加密 args 中的值，未指定值时从 in 中逐行读取，
+// 加密结果写入 out，可以直接粘贴到配置文件中
+func RunEncryptCommand(args []string, in io.Reader, out io.Writer) error {
+	key, err := LoadSecretKey(consts.EnvPrefix)
+	if err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("%w: set %s_%s or %s_%s", consts.ErrConfigSecretKeyMissing,
+			consts.EnvPrefix, EnvSecretKey, consts.EnvPrefix, EnvSecretKeyFile)
+	}
+
+	values := args
+	if len(values) == 0 {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			values = append(values, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	for _, value := range values {
+		secret, err := EncryptSecret(key, value)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(out, secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
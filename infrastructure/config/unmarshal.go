@@ -0,0 +1,256 @@
+package config
+
+import (
+	"ddd-demo/common/consts"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+)
+
+// 结构体绑定配置时使用的 tag
+const (
+	// TagName 配置 Key 的 tag，与 viper 保持一致
+	TagName = "mapstructure"
+	// TagDefault 默认值的 tag，比如 `default:"3000"`，切片使用 , 分隔
+	TagDefault = "default"
+	// TagValidate 校验规则的 tag，比如 `validate:"required,gte=1,oneof=debug info"`
+	TagValidate = "validate"
+)
+
+// InvalidConfigError 配置校验失败，列出了所有不合法的 Key
+type InvalidConfigError struct {
+	// Key 绑定的配置节
+	Key string
+	// Problems 每个不合法 Key 的错误信息
+	Problems []string
+}
+
+func (e *InvalidConfigError) Error() string {
+	return fmt.Sprintf("%s %q:\n  %s", consts.ErrConfigInvalid, e.Key, strings.Join(e.Problems, "\n  "))
+}
+
+func (e *InvalidConfigError) Unwrap() error {
+	return consts.ErrConfigInvalid
+}
+
+var (
+	defaultValidatorOnce sync.Once
+	defaultValidator     *validator.Validate
+)
+
+// getValidator 获取校验器单例，校验错误中的字段名使用配置 Key
+func getValidator() *validator.Validate {
+	defaultValidatorOnce.Do(func() {
+		defaultValidator = validator.New()
+		defaultValidator.SetTagName(TagValidate)
+		defaultValidator.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get(TagName), ",", 2)[0]
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	})
+	return defaultValidator
+}
+
+// Unmarshal 将 key 对应的配置节绑定到 out 指向的结构体，key 为空时绑定全部配置。
+// 先使用 default tag 设置默认值，再使用配置覆盖，最后按 validate tag 校验，
+// 校验失败时返回 *InvalidConfigError，其中列出了所有不合法的 Key
+func (v *ViperConfiguration) Unmarshal(key string, out interface{}) error {
+	return unmarshal(v.current(), key, out)
+}
+
+// unmarshal 将配置节绑定到结构体并校验
+func unmarshal(state *viperState, key string, out interface{}) error {
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return consts.ErrConfigUnmarshalTarget
+	}
+
+	var problems []string
+	applyDefaults(value.Elem(), key, &problems)
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           out,
+		TagName:          TagName,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(sectionSettings(state, key)); err != nil {
+		if decodeErr, ok := err.(*mapstructure.Error); ok {
+			problems = append(problems, decodeErr.Errors...)
+		} else {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if err := getValidator().Struct(out); err != nil {
+		validationErrs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return err
+		}
+		for _, fieldErr := range validationErrs {
+			problems = append(problems, describeFieldError(key, fieldErr))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &InvalidConfigError{Key: key, Problems: problems}
+	}
+	return nil
+}
+
+// sectionSettings 获取配置节下的所有配置。逐个 Key 读取，以便环境变量与命令行参数的覆盖也能生效
+func sectionSettings(state *viperState, key string) map[string]interface{} {
+	prefix := ""
+	if key != "" {
+		prefix = strings.ToLower(key) + "."
+	}
+	settings := make(map[string]interface{})
+	for _, fullKey := range state.v.AllKeys() {
+		if !strings.HasPrefix(fullKey, prefix) {
+			continue
+		}
+		path := strings.Split(strings.TrimPrefix(fullKey, prefix), ".")
+		node := settings
+		for _, name := range path[:len(path)-1] {
+			child, ok := node[name].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[name] = child
+			}
+			node = child
+		}
+		node[path[len(path)-1]] = state.get(fullKey)
+	}
+	return settings
+}
+
+// describeFieldError 将校验错误转换为包含完整配置 Key 的描述
+func describeFieldError(key string, fieldErr validator.FieldError) string {
+	// Namespace 的第一段是结构体名称，替换为配置节的 Key
+	fieldKey := fieldErr.Namespace()
+	if index := strings.Index(fieldKey, "."); index >= 0 {
+		fieldKey = fieldKey[index+1:]
+	}
+	if key != "" {
+		fieldKey = key + "." + fieldKey
+	}
+	rule := fieldErr.Tag()
+	if fieldErr.Param() != "" {
+		rule += "=" + fieldErr.Param()
+	}
+	return fmt.Sprintf("%s: value %v does not satisfy %q", fieldKey, fieldErr.Value(), rule)
+}
+
+// applyDefaults 使用 default tag 为零值字段设置默认值，递归处理嵌套的结构体
+func applyDefaults(value reflect.Value, key string, problems *[]string) {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		fieldValue := value.Field(i)
+		if !fieldValue.CanSet() {
+			continue
+		}
+		fieldKey := strings.SplitN(field.Tag.Get(TagName), ",", 2)[0]
+		if fieldKey == "" {
+			fieldKey = field.Name
+		}
+		if key != "" {
+			fieldKey = key + "." + fieldKey
+		}
+
+		if fieldValue.Kind() == reflect.Struct && fieldValue.Type() != reflect.TypeOf(time.Time{}) {
+			applyDefaults(fieldValue, fieldKey, problems)
+			continue
+		}
+		if fieldValue.Kind() == reflect.Ptr && fieldValue.Type().Elem().Kind() == reflect.Struct {
+			if !fieldValue.IsNil() {
+				applyDefaults(fieldValue.Elem(), fieldKey, problems)
+			}
+			continue
+		}
+
+		defaultValue, found := field.Tag.Lookup(TagDefault)
+		if !found || !fieldValue.IsZero() {
+			continue
+		}
+		if err := setDefault(fieldValue, defaultValue); err != nil {
+			*problems = append(*problems, fmt.Sprintf("%s: invalid default %q: %v", fieldKey, defaultValue, err))
+		}
+	}
+}
+
+// setDefault 将字符串形式的默认值转换为字段的类型并设置
+func setDefault(fieldValue reflect.Value, defaultValue string) error {
+	if fieldValue.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(defaultValue)
+		if err != nil {
+			return err
+		}
+		fieldValue.SetInt(int64(duration))
+		return nil
+	}
+
+	switch fieldValue.Kind() {
+	case reflect.String:
+		fieldValue.SetString(defaultValue)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(defaultValue)
+		if err != nil {
+			return err
+		}
+		fieldValue.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(defaultValue, 10, fieldValue.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fieldValue.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(defaultValue, 10, fieldValue.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fieldValue.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(defaultValue, fieldValue.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fieldValue.SetFloat(f)
+	case reflect.Slice:
+		items := strings.Split(defaultValue, ",")
+		slice := reflect.MakeSlice(fieldValue.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setDefault(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		fieldValue.Set(slice)
+	default:
+		return consts.ErrConfigUnsupportedDefault
+	}
+	return nil
+}
+
+// SectionValidator 返回校验配置节的函数，可以放入 Options.Validators，
+// 这样启动以及重新加载配置时，配置节都需要通过校验才会生效
+func SectionValidator(key string, newOut func() interface{}) func(Configuration) error {
+	return func(conf Configuration) error {
+		return conf.Unmarshal(key, newOut())
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce 配置文件变化后，等待该时长没有新的变化再重新加载，避免编辑器多次写入引起重复加载
+const reloadDebounce = 200 * time.Millisecond
+
+// subscriber 订阅某个 Key 变化的回调函数
+type subscriber struct {
+	key string
+	fn  func(oldValue, newValue interface{})
+}
+
+// Watch 订阅 Key 的变化，重新加载配置后，如果 Key 的值发生了变化，那么调用 fn。
+// Key 可以是某一节配置，比如 server，此时其中任意配置的变化都会触发回调
+func (v *ViperConfiguration) Watch(key string, fn func(oldValue, newValue interface{})) {
+	if fn == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.subscribers = append(v.subscribers, &subscriber{key: key, fn: fn})
+}
+
+// notify 比较新旧配置，通知值发生了变化的订阅者
+func notify(subscribers []*subscriber, oldState, newState *viperState) {
+	for _, s := range subscribers {
+		oldValue, newValue := oldState.get(s.key), newState.get(s.key)
+		if !reflect.DeepEqual(oldValue, newValue) {
+			s.fn(oldValue, newValue)
+		}
+	}
+}
+
+// fileWatcher 监听配置文件所在的目录，以便处理编辑器先删除再创建文件、Kubernetes ConfigMap 替换软链接等情况
+type fileWatcher struct {
+	watcher  *fsnotify.Watcher
+	onChange func()
+
+	mu    sync.Mutex
+	files map[string]bool
+	dirs  map[string]bool
+	timer *time.Timer
+}
+
+// newFileWatcher 创建配置文件监听器，文件变化时调用 onChange
+func newFileWatcher(onChange func()) (*fileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	f := &fileWatcher{
+		watcher:  watcher,
+		onChange: onChange,
+		files:    make(map[string]bool),
+		dirs:     make(map[string]bool),
+	}
+	go f.run()
+	return f, nil
+}
+
+// watch 设置需要监听的配置文件
+func (f *fileWatcher) watch(files []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files = make(map[string]bool)
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		if absFile, err := filepath.Abs(file); err == nil {
+			file = absFile
+		}
+		f.files[filepath.Clean(file)] = true
+		dir := filepath.Dir(file)
+		if f.dirs[dir] {
+			continue
+		}
+		if err := f.watcher.Add(dir); err != nil {
+			fmt.Println("watch config dir error:", err)
+			continue
+		}
+		f.dirs[dir] = true
+	}
+}
+
+// isRelevant 事件是否与监听的配置文件有关
+func (f *fileWatcher) isRelevant(event fsnotify.Event) bool {
+	if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+		return false
+	}
+	name := filepath.Clean(event.Name)
+	// Kubernetes 通过替换 ..data 软链接更新 ConfigMap
+	if strings.HasPrefix(filepath.Base(name), "..") {
+		return true
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.files[name]
+}
+
+// run 处理文件事件，合并短时间内的多次变化
+func (f *fileWatcher) run() {
+	for {
+		select {
+		case event, ok := <-f.watcher.Events:
+			if !ok {
+				return
+			}
+			if !f.isRelevant(event) {
+				continue
+			}
+			f.mu.Lock()
+			if f.timer != nil {
+				f.timer.Stop()
+			}
+			f.timer = time.AfterFunc(reloadDebounce, f.onChange)
+			f.mu.Unlock()
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Println("watch config error:", err)
+		}
+	}
+}
+
+// close 停止监听
+func (f *fileWatcher) close() error {
+	f.mu.Lock()
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	f.mu.Unlock()
+	return f.watcher.Close()
+}
@@ -2,73 +2,382 @@ package config
 
 import (
 	"ddd-demo/common/consts"
+	"fmt"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 )
 
-// ViperConfiguration 使用 viper 类库实现的配置读取服务
+// ViperConfiguration 使用 viper 类库实现的配置读取服务。
+// 配置按层合并，优先级从低到高依次为：默认值、配置文件、远程配置中心、环境变量、命令行参数。
+// ENC(...) 形式的配置值会在读取时被透明地解密。
+// 配置文件变化时可以重新加载，新配置通过校验后整体替换旧配置
 type ViperConfiguration struct {
+	options *Options
+	// state 当前生效的配置，类型为 *viperState，重新加载时整体替换
+	state atomic.Value
+
+	// mu 保护 validators 与 subscribers，并保证同一时间只有一个重新加载
+	mu          sync.Mutex
+	validators  []func(Configuration) error
+	subscribers []*subscriber
+	// watcher 监听配置文件的变化
+	watcher *fileWatcher
+	// remoteWatcher 监听远程配置的变化
+	remoteWatcher *remoteWatcher
+}
+
+// viperState 一次加载得到的配置
+type viperState struct {
 	v *viper.Viper
+	// defaults 默认值，Key 为小写
+	defaults map[string]interface{}
+	// flags 命令行参数中的配置项，Key 为小写
+	flags map[string]string
+	// profile 当前激活的 Profile
+	profile string
+	// files 加载的配置文件
+	files []string
+	// secrets 解密 ENC(...) 形式的加密值
+	secrets *secretResolver
+	// remote 远程配置，未配置远程配置中心时为 nil
+	remote *RemoteSnapshot
+}
+
+// get 获取配置值，其中 ENC(...) 形式的加密值会被替换为明文
+func (s *viperState) get(key string) interface{} {
+	return s.secrets.resolve(s.v.Get(key))
+}
+
+// Options 配置的加载选项
+type Options struct {
+	// ConfigName 配置文件名，不含扩展名，格式由找到的文件的扩展名决定，支持 SupportedConfigTypes 中的格式
+	ConfigName string
+	// ConfigPaths 配置文件的搜索路径，按顺序搜索，使用第一个找到的配置文件
+	ConfigPaths []string
+	// ConfigFiles 指定的配置文件，按顺序深度合并，比如基础配置文件加上密钥配置文件。
+	// 为空时依次从 --config 参数和 <EnvPrefix>_CONFIG 环境变量中读取，仍为空时在 ConfigPaths 中搜索 ConfigName
+	ConfigFiles []string
+	// EnvPrefix 环境变量前缀，比如 DDD 表示 DDD_SERVER_PORT 对应 server.port，为空时不读取环境变量
+	EnvPrefix string
+	// Args 命令行参数，通常为 os.Args[1:]
+	Args []string
+	// Defaults 默认值，Key 使用 . 分隔的完整路径，比如 server.port
+	Defaults map[string]interface{}
+	// Profile 激活的 Profile，比如 dev、test、prod，为空时依次从 --profile 参数和 <EnvPrefix>_PROFILE 环境变量中读取。
+	// 激活后会将 <ConfigName>.<Profile> 配置文件深度合并到基础配置文件之上
+	Profile string
+	// WatchFiles 是否监听配置文件的变化，变化后自动重新加载
+	WatchFiles bool
+	// Validators 配置的校验函数，初次加载以及重新加载时都会执行，任意一个返回错误时加载失败
+	Validators []func(Configuration) error
+	// SecretKey 解密 ENC(...) 形式配置值的 AES 密钥，为空时通过 LoadSecretKey 从环境变量或者密钥文件中读取
+	SecretKey []byte
+	// Remote 远程配置中心，为空时不读取。远程配置覆盖配置文件，同时会被环境变量以及命令行参数覆盖
+	Remote RemoteSource
+	// RemotePollInterval 远程配置中心不支持长轮询时的轮询间隔，以及监听出错后的重试间隔。
+	// 为 0 时使用 DefaultRemotePollInterval，小于 0 时不监听远程配置的变化
+	RemotePollInterval time.Duration
+	// RemoteTimeout 读取远程配置的超时时间，为 0 时使用 DefaultRemoteTimeout
+	RemoteTimeout time.Duration
+	// RemoteCacheFile 远程配置的本地缓存文件，配置中心不可用时使用其中最近一次读取到的配置，为空时不缓存
+	RemoteCacheFile string
+}
+
+// DefaultSettings 默认配置
+var DefaultSettings = map[string]interface{}{
+	"server.appName":           "ddd-demo",
+	"server.port":              8080,
+	"server.shutdownTimeoutTS": 1000,
+	"server.logWithBody":       false,
+}
+
+// current 获取当前生效的配置
+func (v *ViperConfiguration) current() *viperState {
+	return v.state.Load().(*viperState)
+}
+
+// Get 获取任意类型配置
+func (v *ViperConfiguration) Get(key string) interface{} {
+	return v.current().get(key)
+}
+
+// AllKeys 获取所有配置的 Key，只通过环境变量设置的配置不包含在内
+func (v *ViperConfiguration) AllKeys() []string {
+	return v.current().v.AllKeys()
 }
 
 // GetBool 获取 bool 类型配置
 func (v *ViperConfiguration) GetBool(key string) bool {
-	return v.v.GetBool(key)
+	return cast.ToBool(v.current().get(key))
 }
 
 // GetInt 获取 int 类型配置
 func (v *ViperConfiguration) GetInt(key string) int {
-	return v.v.GetInt(key)
+	return cast.ToInt(v.current().get(key))
 }
 
 // GetFloat64 获取 float64 类型配置
 func (v *ViperConfiguration) GetFloat64(key string) float64 {
-	return v.v.GetFloat64(key)
+	return cast.ToFloat64(v.current().get(key))
 }
 
 // GetString 获取 string 类型配置
 func (v *ViperConfiguration) GetString(key string) string {
-	return v.v.GetString(key)
+	return cast.ToString(v.current().get(key))
 }
 
 // GetStringSlice 获取 string 数组类型配置
 func (v *ViperConfiguration) GetStringSlice(key string) []string {
-	return v.v.GetStringSlice(key)
+	return cast.ToStringSlice(v.current().get(key))
 }
 
 // GetStringMap 获取 map 类型配置
 func (v *ViperConfiguration) GetStringMap(key string) map[string]interface{} {
-	return v.v.GetStringMap(key)
+	return cast.ToStringMap(v.current().get(key))
 }
 
 // GetStringMapString 获取 map 类型配置
 func (v *ViperConfiguration) GetStringMapString(key string) map[string]string {
-	return v.v.GetStringMapString(key)
+	return cast.ToStringMapString(v.current().get(key))
 }
 
 // GetStringMapStringSlice 获取 map 类型配置
 func (v *ViperConfiguration) GetStringMapStringSlice(key string) map[string][]string {
-	return v.v.GetStringMapStringSlice(key)
+	return cast.ToStringMapStringSlice(v.current().get(key))
 }
 
-// NewViperConfiguration 创建 viper 配置实例
-func NewViperConfiguration(configName string, configPaths ...string) (Configuration, error) {
-	v := &ViperConfiguration{v: viper.New()}
-	v.v.SetConfigType("yaml")
-	v.v.SetConfigName(configName)
-	for _, configPath := range configPaths {
-		v.v.AddConfigPath(configPath)
+// Source 获取配置的来源层，未配置时返回 SourceNone
+func (v *ViperConfiguration) Source(key string) string {
+	return v.current().source(v.options, key)
+}
+
+// source 获取配置的来源层
+func (s *viperState) source(options *Options, key string) string {
+	key = strings.ToLower(key)
+	if _, found := s.flags[key]; found {
+		return SourceFlag
+	}
+	if options.EnvPrefix != "" {
+		if value, found := lookupEnv(options.EnvPrefix, key); found && value != "" {
+			return SourceEnv
+		}
+	}
+	if s.remote != nil {
+		if _, found := s.remote.Values[key]; found {
+			return SourceRemote
+		}
+	}
+	if s.v.InConfig(key) {
+		return SourceFile
+	}
+	if _, found := s.defaults[key]; found {
+		return SourceDefault
 	}
-	if err := v.v.ReadInConfig(); err != nil {
+	return SourceNone
+}
+
+// Profile 获取当前激活的 Profile，未激活时返回空字符串
+func (v *ViperConfiguration) Profile() string {
+	return v.current().profile
+}
+
+// AddValidator 添加配置的校验函数，之后重新加载配置时，新配置需要通过校验才会生效
+func (v *ViperConfiguration) AddValidator(validator func(Configuration) error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.validators = append(v.validators, validator)
+}
+
+// Reload 重新加载配置，新配置通过校验后整体替换旧配置，并通知订阅了变化的 Key 的回调函数。
+// 加载或校验失败时，旧配置继续生效
+func (v *ViperConfiguration) Reload() error {
+	v.mu.Lock()
+	state, err := loadState(v.options)
+	if err == nil {
+		err = v.validate(state)
+	}
+	if err != nil {
+		v.mu.Unlock()
+		return err
+	}
+	oldState := v.current()
+	v.state.Store(state)
+	if v.watcher != nil {
+		v.watcher.watch(state.files)
+	}
+	subscribers := make([]*subscriber, len(v.subscribers))
+	copy(subscribers, v.subscribers)
+	v.mu.Unlock()
+
+	notify(subscribers, oldState, state)
+	return nil
+}
+
+// validate 使用所有的校验函数校验配置，调用时需要持有 mu
+func (v *ViperConfiguration) validate(state *viperState) error {
+	candidate := &ViperConfiguration{options: v.options}
+	candidate.state.Store(state)
+	for _, validator := range v.validators {
+		if err := validator(candidate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close 停止监听配置文件以及远程配置的变化
+func (v *ViperConfiguration) Close() error {
+	v.mu.Lock()
+	remoteWatcher := v.remoteWatcher
+	v.remoteWatcher = nil
+	v.mu.Unlock()
+	// 监听协程可能正在等待 mu 以重新加载配置，需要在释放 mu 之后等待其退出
+	if remoteWatcher != nil {
+		remoteWatcher.close()
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.watcher == nil {
+		return nil
+	}
+	err := v.watcher.close()
+	v.watcher = nil
+	return err
+}
+
+// loadState 按默认值、配置文件、远程配置、环境变量、命令行参数的顺序加载配置
+func loadState(options *Options) (*viperState, error) {
+	cmd, err := parseFlags(options.Args)
+	if err != nil {
 		return nil, err
 	}
+	profile := options.Profile
+	if profile == "" {
+		profile = resolveControl(cmd, FlagProfile, options.EnvPrefix, EnvProfile)
+	}
+	state := &viperState{
+		v:        viper.New(),
+		defaults: make(map[string]interface{}),
+		flags:    cmd.overrides,
+		profile:  profile,
+	}
+
+	for key, value := range options.Defaults {
+		state.defaults[strings.ToLower(key)] = value
+		state.v.SetDefault(key, value)
+	}
+
+	if state.files, err = resolveConfigFiles(options, cmd); err != nil {
+		return nil, err
+	}
+	// 将 Profile 配置文件深度合并到基础配置之上，Profile 配置文件名为第一个配置文件名加上 Profile，比如 config.dev.yaml
+	if profile != "" {
+		base := filepath.Base(state.files[0])
+		name := strings.TrimSuffix(base, filepath.Ext(base)) + "." + profile
+		profileFile, err := findConfigFile(name, profileSearchPaths(options, state.files))
+		if err != nil {
+			return nil, fmt.Errorf("load profile %s: %w", profile, err)
+		}
+		state.files = append(state.files, profileFile)
+	}
+	if err := readConfigFiles(state.v, state.files); err != nil {
+		return nil, err
+	}
+
+	if options.Remote != nil {
+		if state.remote, err = loadRemote(options); err != nil {
+			return nil, err
+		}
+		if err := state.v.MergeConfigMap(state.remote.nested()); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.EnvPrefix != "" {
+		state.v.SetEnvPrefix(options.EnvPrefix)
+		state.v.SetEnvKeyReplacer(envReplacer)
+		state.v.AutomaticEnv()
+	}
+
+	for key, value := range cmd.overrides {
+		state.v.Set(key, value)
+	}
+
+	// 预先解密所有加密值，密钥缺失或者解密失败时加载失败
+	secretKey := options.SecretKey
+	if len(secretKey) == 0 {
+		if secretKey, err = LoadSecretKey(options.EnvPrefix); err != nil {
+			return nil, err
+		}
+	}
+	state.secrets = &secretResolver{key: secretKey, plaintexts: make(map[string]string)}
+	for _, key := range state.v.AllKeys() {
+		if err := state.secrets.collect(key, state.v.Get(key)); err != nil {
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+// NewLayeredConfiguration 按默认值、配置文件（基础配置文件以及 Profile 配置文件）、远程配置、环境变量、命令行参数的顺序加载配置，
+// 后加载的覆盖先加载的
+func NewLayeredConfiguration(options *Options) (Configuration, error) {
+	state, err := loadState(options)
+	if err != nil {
+		return nil, err
+	}
+	v := &ViperConfiguration{
+		options:    options,
+		validators: append([]func(Configuration) error{}, options.Validators...),
+	}
+	if err := v.validate(state); err != nil {
+		return nil, err
+	}
+	v.state.Store(state)
+
+	if options.WatchFiles {
+		watcher, err := newFileWatcher(func() {
+			if err := v.Reload(); err != nil {
+				fmt.Println("reload config error:", err)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		watcher.watch(state.files)
+		v.watcher = watcher
+	}
+
+	if options.Remote != nil && options.RemotePollInterval >= 0 {
+		v.remoteWatcher = newRemoteWatcher(options.Remote, options.RemotePollInterval, state.remote.Version, func() {
+			if err := v.Reload(); err != nil {
+				fmt.Println("reload config error:", err)
+			}
+		})
+	}
+
 	return v, nil
 }
 
-// NewDefaultYamlConfiguration 创建默认的配置实例
+// NewViperConfiguration 创建 viper 配置实例，只读取在 configPaths 中找到的 configName 配置文件
+func NewViperConfiguration(configName string, configPaths ...string) (Configuration, error) {
+	return NewLayeredConfiguration(&Options{ConfigName: configName, ConfigPaths: configPaths})
+}
+
+// NewDefaultYamlConfiguration 创建默认的配置实例，依次加载 DefaultSettings、配置文件（--config 参数或者 DDD_CONFIG 环境变量
+// 指定的文件，未指定时使用工作目录或者 ./config 下的配置文件）、
+// 远程配置目录（通过 --remote-config-dir 参数或者 DDD_REMOTE_CONFIG_DIR 环境变量指定）、
+// DDD_ 开头的环境变量以及命令行参数，并在配置文件变化时自动重新加载
 func NewDefaultYamlConfiguration() (Configuration, error) {
 	var paths []string
 	if wd, err := os.Getwd(); err == nil {
@@ -76,7 +385,37 @@ func NewDefaultYamlConfiguration() (Configuration, error) {
 	} else {
 		return nil, err
 	}
-	return NewViperConfiguration(consts.ConfigName, paths...)
+	options := &Options{
+		ConfigName:  consts.ConfigName,
+		ConfigPaths: paths,
+		EnvPrefix:   consts.EnvPrefix,
+		Args:        os.Args[1:],
+		Defaults:    DefaultSettings,
+		WatchFiles:  true,
+	}
+	cmd, err := parseFlags(options.Args)
+	if err != nil {
+		return nil, err
+	}
+	if dir := resolveControl(cmd, FlagRemoteConfigDir, options.EnvPrefix, EnvRemoteConfigDir); dir != "" {
+		options.Remote = NewDirectorySource(dir)
+		options.RemoteCacheFile = defaultRemoteCacheFile()
+	}
+	return NewLayeredConfiguration(options)
+}
+
+// defaultRemoteCacheFile 默认的远程配置缓存文件，位于当前用户的缓存目录下以可执行文件命名的目录中，
+// 获取不到缓存目录时使用临时目录下带用户 ID 的目录，避免多个应用或者用户共用同一个文件
+func defaultRemoteCacheFile() string {
+	app := filepath.Base(os.Args[0])
+	if executable, err := os.Executable(); err == nil {
+		app = filepath.Base(executable)
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", consts.ConfigName, os.Getuid()))
+	}
+	return filepath.Join(dir, app, consts.ConfigName+".remote.json")
 }
 
 var (
@@ -2,17 +2,22 @@ package es
 
 import (
 	"bytes"
+	"context"
 	"ddd-demo/common/consts"
+	"ddd-demo/common/factory"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
-	"sync"
+	"net/http"
+	"net/url"
 
 	"github.com/elastic/go-elasticsearch/v7"
 )
 
 // ESClient ES 客户端
 type ESClient struct {
-	esClient *elasticsearch.Client
+	esClient  *elasticsearch.Client
+	transport *http.Transport
 }
 
 // QueryIndexData 查询索引数据
@@ -49,25 +54,51 @@ func (e *ESClient) QueryIndexData(index string, params map[string]interface{}) (
 	return result, err
 }
 
-var (
-	defaultESClientOnce sync.Once
-	defaultESClient     *ESClient
-)
+// Ping 检查是否可以连接到 ES
+func (e *ESClient) Ping(ctx context.Context) error {
+	resp, err := e.esClient.Ping(e.esClient.Ping.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.IsError() {
+		return fmt.Errorf("%w: %s", consts.ErrESPing, resp.Status())
+	}
+	return nil
+}
 
-// NewESClient 创建 ES 客户端
-func NewESClient(host, username, password string) *ESClient {
-	defaultESClientOnce.Do(func() {
-		client, err := elasticsearch.NewClient(elasticsearch.Config{
-			Addresses:  []string{host},
-			Username:   username,
-			Password:   password,
-			MaxRetries: 3,
-		})
-		if err == nil {
-			defaultESClient = &ESClient{
-				esClient: client,
+// Close 关闭空闲的连接
+func (e *ESClient) Close() error {
+	e.transport.CloseIdleConnections()
+	return nil
+}
+
+// NewESClient 创建 ES 客户端，相同的地址与用户共用一个客户端，工厂销毁时关闭
+func NewESClient(objectFactory *factory.ObjectFactory, host, username, password string) (*ESClient, error) {
+	key := (&url.URL{Scheme: "es", User: url.UserPassword(username, password), Host: host}).String()
+	client, err := objectFactory.GetContext(context.Background(), key, &factory.Provider{
+		Create: func(_ context.Context, _ string) (interface{}, error) {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			client, err := elasticsearch.NewClient(elasticsearch.Config{
+				Addresses:  []string{host},
+				Username:   username,
+				Password:   password,
+				MaxRetries: 3,
+				Transport:  transport,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", consts.ErrESNewClient, err)
 			}
-		}
+			return &ESClient{esClient: client, transport: transport}, nil
+		},
+		Destroy: func(obj interface{}) error {
+			return obj.(*ESClient).Close()
+		},
 	})
-	return defaultESClient
+	if err != nil {
+		return nil, err
+	}
+	return client.(*ESClient), nil
 }
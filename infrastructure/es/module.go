@@ -0,0 +1,45 @@
+package es
+
+import (
+	"ddd-demo/common/factory"
+	"ddd-demo/infrastructure/config"
+	"ddd-demo/infrastructure/health"
+
+	"go.uber.org/fx"
+)
+
+// ConfigKey ES 的配置节
+const ConfigKey = "es"
+
+// Config ES 的配置
+type Config struct {
+	Host     string `mapstructure:"host" validate:"required,url"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// Module 根据 es 配置提供 *ESClient，启动时检查是否可以连接到 ES，同时提供就绪检查。
+// 客户端由 ObjectFactory 管理，依赖 factory.Module，应用停止时由工厂关闭
+var Module = fx.Module(
+	"es",
+	fx.Provide(
+		NewESClientFromConfig,
+		fx.Annotate(func(client *ESClient) *health.Check {
+			return &health.Check{Name: "es", Probe: client.Ping}
+		}, fx.ResultTags(health.CheckGroup)),
+	),
+	fx.Invoke(func(lc fx.Lifecycle, client *ESClient) {
+		lc.Append(fx.Hook{
+			OnStart: client.Ping,
+		})
+	}),
+)
+
+// NewESClientFromConfig 根据配置创建 ES 客户端
+func NewESClientFromConfig(factory *factory.ObjectFactory, conf config.Configuration) (*ESClient, error) {
+	esConfig := &Config{}
+	if err := conf.Unmarshal(ConfigKey, esConfig); err != nil {
+		return nil, err
+	}
+	return NewESClient(factory, esConfig.Host, esConfig.Username, esConfig.Password)
+}
@@ -0,0 +1,75 @@
+package featureflag
+
+import (
+	"ddd-demo/infrastructure/config"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// SettingsKey 功能开关的配置节
+const SettingsKey = "featureFlags"
+
+// Settings 功能开关的配置，比如：
+//
+//	featureFlags:
+//	  flags:
+//	    newCheckout:
+//	      enabled: true
+//	      percentage: 20
+//	      tenants: [tenant-a, tenant-b]
+//
+// 开关名称与配置 Key 一样不区分大小写
+type Settings struct {
+	Flags map[string]*Flag `mapstructure:"flags" validate:"dive"`
+}
+
+// ConfigEvaluator 基于配置的求值器，配置变化后自动重新加载功能开关
+type ConfigEvaluator struct {
+	// flags 当前生效的功能开关，类型为 map[string]*Flag，Key 为小写的开关名称
+	flags atomic.Value
+}
+
+// validatable 支持添加校验函数的配置
+type validatable interface {
+	AddValidator(validator func(config.Configuration) error)
+}
+
+// NewConfigEvaluator 创建基于配置的求值器。配置支持校验时，不合法的功能开关配置会导致重新加载失败，旧配置继续生效
+func NewConfigEvaluator(conf config.Configuration) (Evaluator, error) {
+	e := &ConfigEvaluator{}
+	if err := e.load(conf); err != nil {
+		return nil, err
+	}
+	if v, ok := conf.(validatable); ok {
+		v.AddValidator(config.SectionValidator(SettingsKey, func() interface{} {
+			return &Settings{}
+		}))
+	}
+	conf.Watch(SettingsKey, func(_, _ interface{}) {
+		if err := e.load(conf); err != nil {
+			fmt.Println("reload feature flags error:", err)
+		}
+	})
+	return e, nil
+}
+
+// load 从配置中加载功能开关
+func (e *ConfigEvaluator) load(conf config.Configuration) error {
+	settings := &Settings{}
+	if err := conf.Unmarshal(SettingsKey, settings); err != nil {
+		return err
+	}
+	flags := make(map[string]*Flag, len(settings.Flags))
+	for name, flag := range settings.Flags {
+		flags[strings.ToLower(name)] = flag
+	}
+	e.flags.Store(flags)
+	return nil
+}
+
+// IsEnabled 功能开关 name 对 target 是否开启，开关不存在时返回 false
+func (e *ConfigEvaluator) IsEnabled(name string, target *Target) bool {
+	flags := e.flags.Load().(map[string]*Flag)
+	return flags[strings.ToLower(name)].evaluate(name, target)
+}
@@ -0,0 +1,57 @@
+package featureflag
+
+import "context"
+
+// contextKey 在 context 中保存 Scope 的 Key
+type contextKey struct{}
+
+// Scope 绑定了求值对象的求值器，比如当前请求的用户与租户
+type Scope struct {
+	evaluator Evaluator
+	target    *Target
+	// resolve 不为空时每次求值时获取求值对象
+	resolve func() *Target
+}
+
+// IsEnabled 功能开关 name 对绑定的对象是否开启，没有求值器时返回 false
+func (s *Scope) IsEnabled(name string) bool {
+	if s == nil || s.evaluator == nil {
+		return false
+	}
+	return s.evaluator.IsEnabled(name, s.Target())
+}
+
+// Target 绑定的求值对象
+func (s *Scope) Target() *Target {
+	if s == nil {
+		return nil
+	}
+	if s.resolve != nil {
+		return s.resolve()
+	}
+	return s.target
+}
+
+// NewContext 返回保存了求值器以及求值对象的 context
+func NewContext(ctx context.Context, evaluator Evaluator, target *Target) context.Context {
+	return context.WithValue(ctx, contextKey{}, &Scope{evaluator: evaluator, target: target})
+}
+
+// NewLazyContext 返回保存了求值器的 context，求值对象在每次求值时通过 resolve 获取，
+// 比如请求开始时还未鉴权，鉴权中间件之后才能确定用户与租户
+func NewLazyContext(ctx context.Context, evaluator Evaluator, resolve func() *Target) context.Context {
+	return context.WithValue(ctx, contextKey{}, &Scope{evaluator: evaluator, resolve: resolve})
+}
+
+// FromContext 获取 context 中的 Scope，不存在时返回的 Scope 对所有开关返回 false
+func FromContext(ctx context.Context) *Scope {
+	if scope, ok := ctx.Value(contextKey{}).(*Scope); ok {
+		return scope
+	}
+	return &Scope{}
+}
+
+// IsEnabled 使用 context 中的 Scope 求值
+func IsEnabled(ctx context.Context, name string) bool {
+	return FromContext(ctx).IsEnabled(name)
+}
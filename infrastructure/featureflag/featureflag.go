@@ -0,0 +1,80 @@
+package featureflag
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// percentageBuckets 百分比放量时的分桶数量，精度为 0.01%
+const percentageBuckets = 10000
+
+// Target 功能开关的求值对象
+type Target struct {
+	// UserID 用户 ID，百分比放量时以其作为分桶依据
+	UserID string
+	// TenantID 租户 ID，用于匹配租户白名单
+	TenantID string
+}
+
+// Evaluator 功能开关的求值器
+type Evaluator interface {
+	// IsEnabled 功能开关 name 对 target 是否开启，开关不存在时返回 false，target 可以为 nil
+	IsEnabled(name string, target *Target) bool
+}
+
+// Flag 功能开关，Enabled 为总开关，Tenants 与 Percentage 可以组合使用：
+// 先按租户白名单过滤，再在白名单内按百分比放量
+type Flag struct {
+	// Enabled 是否开启，为 false 时对所有对象关闭
+	Enabled bool `mapstructure:"enabled"`
+	// Percentage 按用户 ID 放量的百分比，取值范围为 0 到 100，为空时不限制。
+	// 同一用户的结果是稳定的，提高百分比时已经开启的用户保持开启
+	Percentage *float64 `mapstructure:"percentage" validate:"omitempty,gte=0,lte=100"`
+	// Tenants 租户白名单，为空时不限制
+	Tenants []string `mapstructure:"tenants"`
+}
+
+// evaluate 对 target 求值
+func (f *Flag) evaluate(name string, target *Target) bool {
+	if f == nil || !f.Enabled {
+		return false
+	}
+	if target == nil {
+		target = &Target{}
+	}
+	if len(f.Tenants) > 0 && !contains(f.Tenants, target.TenantID) {
+		return false
+	}
+	if f.Percentage != nil {
+		if *f.Percentage >= 100 {
+			return true
+		}
+		if target.UserID == "" {
+			return false
+		}
+		return float64(bucket(name, target.UserID)) < *f.Percentage*percentageBuckets/100
+	}
+	return true
+}
+
+// bucket 使用一致性哈希计算用户所在的分桶，开关名称参与哈希，使不同开关的放量用户相互独立
+func bucket(name, userID string) uint32 {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(strings.ToLower(name)))
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write([]byte(userID))
+	return hash.Sum32() % percentageBuckets
+}
+
+// contains 租户是否在白名单中
+func contains(tenants []string, tenantID string) bool {
+	if tenantID == "" {
+		return false
+	}
+	for _, tenant := range tenants {
+		if tenant == tenantID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTimeout 默认的单个探针超时时间
+	DefaultTimeout = 2 * time.Second
+	// DefaultCacheTTL 默认的探针结果缓存时间
+	DefaultCacheTTL = 5 * time.Second
+)
+
+// 检查结果的状态
+const (
+	// StatusUp 可用
+	StatusUp = "up"
+	// StatusDown 不可用
+	StatusDown = "down"
+)
+
+// CheckGroup 健康检查在 fx 中的值组，基础设施模块通过 fx.Annotate(..., fx.ResultTags(health.CheckGroup)) 提供 *Check
+const CheckGroup = `group:"health_checks"`
+
+// Probe 探针，返回错误表示依赖不可用，需要在 ctx 超时时返回
+type Probe func(ctx context.Context) error
+
+// Check 一个依赖的健康检查
+type Check struct {
+	// Name 依赖的名称，比如 mysql、redis
+	Name string
+	// Probe 探针
+	Probe Probe
+	// Liveness 为 true 时用于存活检查，否则用于就绪检查。
+	// 存活检查失败时进程会被重启，外部依赖不可用通常只影响就绪
+	Liveness bool
+	// CacheTTL 结果的缓存时间，为 0 时使用 Registry 的默认值，为负数时不缓存
+	CacheTTL time.Duration
+}
+
+// Result 一个依赖的检查结果
+type Result struct {
+	Status    string    `json:"status"`
+	LatencyMS float64   `json:"latencyMs"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Report 健康检查报告，所有依赖可用时 Status 为 up
+type Report struct {
+	Status string             `json:"status"`
+	Checks map[string]*Result `json:"checks"`
+}
+
+// Up 是否所有依赖都可用
+func (r *Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+// entry 注册的检查以及缓存的结果
+type entry struct {
+	check *Check
+	// mu 保证同一时间只有一个探针在执行，其他请求等待并复用结果
+	mu     sync.Mutex
+	result *Result
+}
+
+// Registry 健康检查的注册表，探针并发执行，结果在缓存时间内复用，避免频繁请求依赖
+type Registry struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu      sync.RWMutex
+	entries map[string]*entry
+}
+
+// NewRegistry 创建健康检查的注册表，timeout 为单个探针的超时时间，cacheTTL 为结果的缓存时间
+func NewRegistry(timeout, cacheTTL time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Registry{
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+		entries:  make(map[string]*entry),
+	}
+}
+
+// Register 注册健康检查，同名的检查会被替换
+func (r *Registry) Register(checks ...*Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, check := range checks {
+		if check == nil || check.Probe == nil {
+			continue
+		}
+		r.entries[check.Name] = &entry{check: check}
+	}
+}
+
+// Liveness 执行存活检查
+func (r *Registry) Liveness(ctx context.Context) *Report {
+	return r.run(ctx, true)
+}
+
+// Readiness 执行就绪检查
+func (r *Registry) Readiness(ctx context.Context) *Report {
+	return r.run(ctx, false)
+}
+
+// run 并发执行 liveness 对应的检查
+func (r *Registry) run(ctx context.Context, liveness bool) *Report {
+	r.mu.RLock()
+	entries := make([]*entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		if e.check.Liveness == liveness {
+			entries = append(entries, e)
+		}
+	}
+	r.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].check.Name < entries[j].check.Name
+	})
+
+	results := make([]*Result, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e *entry) {
+			defer wg.Done()
+			results[i] = r.probe(ctx, e)
+		}(i, e)
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusUp, Checks: make(map[string]*Result, len(entries))}
+	for i, e := range entries {
+		report.Checks[e.check.Name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// probe 执行一个探针，缓存未过期时直接返回缓存的结果
+func (r *Registry) probe(ctx context.Context, e *entry) *Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ttl := e.check.CacheTTL
+	if ttl == 0 {
+		ttl = r.cacheTTL
+	}
+	if e.result != nil && ttl > 0 && time.Since(e.result.CheckedAt) < ttl {
+		return e.result
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	start := time.Now()
+	err := e.check.Probe(probeCtx)
+	result := &Result{
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	// 调用方取消时的结果不代表依赖的状态，不缓存
+	if ctx.Err() == nil {
+		e.result = result
+	}
+	return result
+}
@@ -0,0 +1,44 @@
+package health
+
+import (
+	"ddd-demo/infrastructure/config"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// ConfigKey 健康检查的配置节
+const ConfigKey = "health"
+
+// Config 健康检查的配置
+type Config struct {
+	// Timeout 单个探针的超时时间
+	Timeout time.Duration `mapstructure:"timeout" default:"2s" validate:"gt=0"`
+	// CacheTTL 探针结果的缓存时间，为 0 时不缓存
+	CacheTTL time.Duration `mapstructure:"cacheTTL" default:"5s" validate:"gte=0"`
+}
+
+// RegistryParams 创建注册表的参数，基础设施模块向 health_checks 组中提供健康检查
+type RegistryParams struct {
+	fx.In
+
+	Conf   config.Configuration
+	Checks []*Check `group:"health_checks"`
+}
+
+// Module 根据 health 配置提供 *Registry，并注册各模块提供的健康检查
+var Module = fx.Module(
+	"health",
+	fx.Provide(NewRegistryFromConfig),
+)
+
+// NewRegistryFromConfig 根据配置创建健康检查的注册表
+func NewRegistryFromConfig(p RegistryParams) (*Registry, error) {
+	healthConfig := &Config{}
+	if err := p.Conf.Unmarshal(ConfigKey, healthConfig); err != nil {
+		return nil, err
+	}
+	registry := NewRegistry(healthConfig.Timeout, healthConfig.CacheTTL)
+	registry.Register(p.Checks...)
+	return registry, nil
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"context"
+	"ddd-demo/infrastructure/config"
+
+	"github.com/go-resty/resty/v2"
+	"go.uber.org/fx"
+)
+
+// ConfigKey Resty Client 的配置节
+const ConfigKey = "http.client"
+
+// Module 根据 http.client 配置提供 *resty.Client，停止时关闭空闲连接
+var Module = fx.Module(
+	"http",
+	fx.Provide(NewRestyClientFromConfig),
+	fx.Invoke(func(lc fx.Lifecycle, client *resty.Client) {
+		lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				client.GetClient().CloseIdleConnections()
+				return nil
+			},
+		})
+	}),
+)
+
+// NewRestyClientFromConfig 根据配置创建 resty 客户端，未配置的项使用默认值
+func NewRestyClientFromConfig(conf config.Configuration) (*resty.Client, error) {
+	restyClientConfig := &RestyClientConfig{}
+	if err := conf.Unmarshal(ConfigKey, restyClientConfig); err != nil {
+		return nil, err
+	}
+	return NewRestyClient(restyClientConfig), nil
+}
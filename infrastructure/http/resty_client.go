@@ -12,14 +12,14 @@ import (
 
 // RetryStatusCode 用于指定需要进行重试的状态码
 type RetryStatusCode struct {
-	Start int `mapstructure:"start"`
-	End   int `mapstructure:"end"`
+	Start int `mapstructure:"start" validate:"gte=100,lte=999"`
+	End   int `mapstructure:"end" validate:"gtefield=Start,lte=999"`
 }
 
 // ClientCertificate 代表客户端证书
 type ClientCertificate struct {
-	Public  string `mapstructure:"public"`
-	Private string `mapstructure:"private"`
+	Public  string `mapstructure:"public" validate:"required"`
+	Private string `mapstructure:"private" validate:"required"`
 }
 
 // 下面是默认值的定义
@@ -45,35 +45,35 @@ type RestyClientConfig struct {
 	// 是否禁止对请求进行追踪
 	DisableTrace bool `mapstructure:"disable_trace"`
 	// 等待连接完成的总时间
-	ConnectTimeoutMillis int `mapstructure:"connect_timeout_millis"`
+	ConnectTimeoutMillis int `mapstructure:"connect_timeout_millis" validate:"gte=0"`
 	// Keep-Alive 探查的时间间隔
-	KeepAliveTimeoutMillis int `mapstructure:"keepalive_timeout_millis"`
+	KeepAliveTimeoutMillis int `mapstructure:"keepalive_timeout_millis" validate:"gte=0"`
 	// 在假定 IPV6 不可用，降级到 IPV4 之前，等待 IPV6 成功的总时间
-	FallbackDelayTimeoutMillis int `mapstructure:"fallback_delay_timeout_millis"`
+	FallbackDelayTimeoutMillis int `mapstructure:"fallback_delay_timeout_millis" validate:"gte=0"`
 	// 每个 Host 的最大空闲连接数
-	MaxIdleConnsPerHost int `mapstructure:"max_idle_conns_host"`
+	MaxIdleConnsPerHost int `mapstructure:"max_idle_conns_host" validate:"gte=0"`
 	// Client 与所有 Host 的最大空闲连接数
-	MaxIdleConns int `mapstructure:"max_idle_conns"`
+	MaxIdleConns int `mapstructure:"max_idle_conns" validate:"gte=0"`
 	// 空闲连接的超时时间，超时的连接将被关闭
-	IdleConnTimeoutMillis int `mapstructure:"idle_conn_timeout_millis"`
+	IdleConnTimeoutMillis int `mapstructure:"idle_conn_timeout_millis" validate:"gte=0"`
 	// 发送完 Expect: 100-continue 请求后，等待 server 应答的超时时间
-	ExpectContinueTimeoutMillis int `mapstructure:"expect_continue_timeout_millis"`
+	ExpectContinueTimeoutMillis int `mapstructure:"expect_continue_timeout_millis" validate:"gte=0"`
 	// 请求的超时时间
-	TimeoutMillis int `mapstructure:"timeout_millis"`
+	TimeoutMillis int `mapstructure:"timeout_millis" validate:"gte=0"`
 	// 设置代理 URL 和端口
-	Proxy string `mapstructure:"proxy"`
+	Proxy string `mapstructure:"proxy" validate:"omitempty,url"`
 	// 重试次数
-	RetryCount int `mapstructure:"retry_count"`
+	RetryCount int `mapstructure:"retry_count" validate:"gte=0"`
 	// 初始的重试等待时间
-	RetryWaitTimeMillis int `mapstructure:"retry_wait_time_millis"`
+	RetryWaitTimeMillis int `mapstructure:"retry_wait_time_millis" validate:"gte=0"`
 	// 最大的重试等待时间
-	RetryMaxWaitTimeMillis int `mapstructure:"retry_max_wait_time_millis"`
+	RetryMaxWaitTimeMillis int `mapstructure:"retry_max_wait_time_millis" validate:"gte=0"`
 	// 进行重试的状态码
-	RetryStatusCodes []*RetryStatusCode `mapstructure:"retry_status_codes"`
+	RetryStatusCodes []*RetryStatusCode `mapstructure:"retry_status_codes" validate:"dive"`
 	// 信任的根证书列表
 	RootCertificates []string `mapstructure:"root_certificates"`
 	// 客户端证书列表
-	ClientCertificates []*ClientCertificate `mapstructure:"client_certificates"`
+	ClientCertificates []*ClientCertificate `mapstructure:"client_certificates" validate:"dive"`
 	// 是否开启 Debug 模式
 	DebugMode bool `mapstructure:"debug_mode"`
 }
@@ -0,0 +1,304 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"ddd-demo/common/consts"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTTL 默认的租约时长
+	DefaultTTL = 30 * time.Second
+	// MinTTL 最小的租约时长，Redis 的租约以毫秒为单位，并且自动续期的间隔 TTL/3 不能为 0
+	MinTTL = 3 * time.Millisecond
+	// DefaultRetryInterval 默认的获取锁重试间隔
+	DefaultRetryInterval = 100 * time.Millisecond
+	// releaseTimeout Do 释放锁的超时时间
+	releaseTimeout = 5 * time.Second
+)
+
+// Locker 基于租约的分布式锁，租约到期后锁自动释放，避免持有者崩溃后锁永远不能被获取
+type Locker interface {
+	// Acquire 获取锁，锁被占用时每隔 RetryInterval 重试，直到获取成功或者 ctx 结束
+	Acquire(ctx context.Context, key string, opts ...Option) (Lock, error)
+	// TryAcquire 尝试获取一次锁，锁被占用时返回 consts.ErrLockNotAcquired
+	TryAcquire(ctx context.Context, key string, opts ...Option) (Lock, error)
+}
+
+// Lock 已获取的锁
+type Lock interface {
+	// Key 锁的 Key
+	Key() string
+	// Token 持有者令牌，只有持有者可以续期以及释放锁
+	Token() string
+	// FencingToken 栅栏令牌，同一个 Key 每次获取锁时单调递增。
+	// 写入外部存储时带上该令牌，存储拒绝比已见过的令牌更小的写入，可以避免租约过期后旧持有者的写入
+	FencingToken() int64
+	// Extend 将租约延长为从现在开始的 ttl，ttl 小于 MinTTL 时使用 MinTTL，锁已经丢失时返回 consts.ErrLockNotHeld
+	Extend(ctx context.Context, ttl time.Duration) error
+	// Release 释放锁，锁已经丢失时返回 consts.ErrLockNotHeld
+	Release(ctx context.Context) error
+	// Done 锁被释放或者丢失（比如续期时发现锁已经被其他持有者获取）时关闭。
+	// 不自动续期时，租约到期不会关闭 Done
+	Done() <-chan struct{}
+}
+
+// Options 获取锁的选项
+type Options struct {
+	// TTL 租约时长，小于等于 0 时使用 DefaultTTL，小于 MinTTL 时使用 MinTTL
+	TTL time.Duration
+	// RetryInterval Acquire 的重试间隔
+	RetryInterval time.Duration
+	// AutoExtend 持有期间是否每隔 TTL/3 自动续期
+	AutoExtend bool
+}
+
+// Option 用于设置 Options
+type Option func(*Options)
+
+// WithTTL 设置租约时长
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// WithRetryInterval 设置 Acquire 的重试间隔
+func WithRetryInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.RetryInterval = interval
+	}
+}
+
+// WithoutAutoExtend 不自动续期，租约到期后锁自动释放
+func WithoutAutoExtend() Option {
+	return func(o *Options) {
+		o.AutoExtend = false
+	}
+}
+
+// newOptions 根据 Option 列表生成 Options
+func newOptions(opts ...Option) *Options {
+	o := &Options{TTL: DefaultTTL, RetryInterval: DefaultRetryInterval, AutoExtend: true}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	if o.TTL <= 0 {
+		o.TTL = DefaultTTL
+	} else if o.TTL < MinTTL {
+		o.TTL = MinTTL
+	}
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = DefaultRetryInterval
+	}
+	return o
+}
+
+// Do 获取锁后执行 fn，锁丢失时取消 fn 的 ctx，fn 返回后释放锁
+func Do(ctx context.Context, locker Locker, key string, fn func(ctx context.Context) error, opts ...Option) error {
+	l, err := locker.Acquire(ctx, key, opts...)
+	if err != nil {
+		return err
+	}
+	fnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-l.Done():
+			cancel()
+		case <-fnCtx.Done():
+		}
+	}()
+
+	err = fn(fnCtx)
+	releaseCtx, releaseCancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer releaseCancel()
+	if releaseErr := l.Release(releaseCtx); releaseErr != nil && err == nil {
+		return releaseErr
+	}
+	return err
+}
+
+// backend 锁的存储，所有操作都需要是原子的
+type backend interface {
+	// acquire 在 key 未被占用时以 token 占用 ttl，返回递增的栅栏令牌，已被占用时返回 false
+	acquire(ctx context.Context, key, token string, ttl time.Duration) (int64, bool, error)
+	// extend 在 key 被 token 占用时将租约延长为 ttl
+	extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	// release 在 key 被 token 占用时释放
+	release(ctx context.Context, key, token string) (bool, error)
+}
+
+// locker 基于 backend 的 Locker
+type locker struct {
+	backend backend
+}
+
+// Acquire 获取锁，锁被占用时重试
+func (l *locker) Acquire(ctx context.Context, key string, opts ...Option) (Lock, error) {
+	options := newOptions(opts...)
+	for {
+		lease, err := l.tryAcquire(ctx, key, options)
+		if err != consts.ErrLockNotAcquired {
+			return lease, err
+		}
+		timer := time.NewTimer(options.RetryInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("acquire lock %s: %w", key, ctx.Err())
+		}
+	}
+}
+
+// TryAcquire 尝试获取一次锁
+func (l *locker) TryAcquire(ctx context.Context, key string, opts ...Option) (Lock, error) {
+	return l.tryAcquire(ctx, key, newOptions(opts...))
+}
+
+// tryAcquire 尝试获取一次锁，成功时按需开始自动续期
+func (l *locker) tryAcquire(ctx context.Context, key string, options *Options) (Lock, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(buf)
+	// 租约从发出请求时开始计算，请求耗时越长租约剩余时间越短
+	start := time.Now()
+	fence, ok, err := l.backend.acquire(ctx, key, token, options.TTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, consts.ErrLockNotAcquired
+	}
+
+	lease := &lease{
+		backend: l.backend,
+		key:     key,
+		token:   token,
+		fence:   fence,
+		done:    make(chan struct{}),
+	}
+	if options.AutoExtend {
+		lease.stop = make(chan struct{})
+		lease.stopped = make(chan struct{})
+		go lease.autoExtend(options.TTL, start)
+	}
+	return lease, nil
+}
+
+// lease 已获取的锁
+type lease struct {
+	backend backend
+	key     string
+	token   string
+	fence   int64
+
+	doneOnce sync.Once
+	done     chan struct{}
+	// stop 以及 stopped 用于停止自动续期，不自动续期时为 nil
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// Key 锁的 Key
+func (l *lease) Key() string {
+	return l.key
+}
+
+// Token 持有者令牌
+func (l *lease) Token() string {
+	return l.token
+}
+
+// FencingToken 栅栏令牌
+func (l *lease) FencingToken() int64 {
+	return l.fence
+}
+
+// Done 锁被释放或者丢失时关闭
+func (l *lease) Done() <-chan struct{} {
+	return l.done
+}
+
+// Extend 延长租约
+func (l *lease) Extend(ctx context.Context, ttl time.Duration) error {
+	if ttl < MinTTL {
+		ttl = MinTTL
+	}
+	ok, err := l.backend.extend(ctx, l.key, l.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		l.lost()
+		return fmt.Errorf("%w: %s", consts.ErrLockNotHeld, l.key)
+	}
+	return nil
+}
+
+// Release 停止自动续期并释放锁
+func (l *lease) Release(ctx context.Context) error {
+	if l.stop != nil {
+		l.stopOnce.Do(func() {
+			close(l.stop)
+		})
+		<-l.stopped
+	}
+	defer l.lost()
+	ok, err := l.backend.release(ctx, l.key, l.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", consts.ErrLockNotHeld, l.key)
+	}
+	return nil
+}
+
+// lost 标记锁已经释放或者丢失
+func (l *lease) lost() {
+	l.doneOnce.Do(func() {
+		close(l.done)
+	})
+}
+
+// autoExtend 每隔 ttl/3 续期一次，extendedAt 为获取锁请求发出的时间。
+// 续期出错时继续重试，下一次续期之前租约可能到期时认为锁丢失，不等到租约到期之后
+func (l *lease) autoExtend(ttl time.Duration, extendedAt time.Time) {
+	defer close(l.stopped)
+	interval := ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-l.done:
+			return
+		case <-ticker.C:
+			// 续期请求不超过租约到期的时间
+			ctx, cancel := context.WithDeadline(context.Background(), extendedAt.Add(ttl))
+			start := time.Now()
+			ok, err := l.backend.extend(ctx, l.key, l.token, ttl)
+			cancel()
+			if err == nil && ok {
+				extendedAt = start
+				continue
+			}
+			if (err == nil && !ok) || time.Since(extendedAt)+interval >= ttl {
+				l.lost()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryEntry 内存中的锁
+type memoryEntry struct {
+	token     string
+	expiresAt time.Time
+}
+
+// memoryBackend 基于进程内存的锁存储
+type memoryBackend struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	fences  map[string]int64
+}
+
+// NewMemoryLocker 创建基于进程内存的锁，语义与 Redis 实现相同，用于测试或者单副本部署
+func NewMemoryLocker() Locker {
+	return &locker{backend: &memoryBackend{
+		entries: make(map[string]*memoryEntry),
+		fences:  make(map[string]int64),
+	}}
+}
+
+// held 获取未过期的锁
+func (b *memoryBackend) held(key string) *memoryEntry {
+	entry, found := b.entries[key]
+	if !found {
+		return nil
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(b.entries, key)
+		return nil
+	}
+	return entry
+}
+
+// acquire 获取锁
+func (b *memoryBackend) acquire(_ context.Context, key, token string, ttl time.Duration) (int64, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.held(key) != nil {
+		return 0, false, nil
+	}
+	b.entries[key] = &memoryEntry{token: token, expiresAt: time.Now().Add(ttl)}
+	b.fences[key]++
+	return b.fences[key], true, nil
+}
+
+// extend 续期
+func (b *memoryBackend) extend(_ context.Context, key, token string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entry := b.held(key)
+	if entry == nil || entry.token != token {
+		return false, nil
+	}
+	entry.expiresAt = time.Now().Add(ttl)
+	return true, nil
+}
+
+// release 释放锁
+func (b *memoryBackend) release(_ context.Context, key, token string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entry := b.held(key)
+	if entry == nil || entry.token != token {
+		return false, nil
+	}
+	delete(b.entries, key)
+	return true, nil
+}
@@ -0,0 +1,9 @@
+package lock
+
+import "go.uber.org/fx"
+
+// Module 基于 persistence.RedisModule 提供的 *redis.Client 提供 Locker
+var Module = fx.Module(
+	"lock",
+	fx.Provide(NewRedisLocker),
+)
@@ -0,0 +1,81 @@
+package lock
+
+import (
+	"context"
+	"ddd-demo/common/factory"
+	"ddd-demo/infrastructure/persistence"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultRedisPrefix 默认的 Redis Key 前缀
+const DefaultRedisPrefix = "lock:"
+
+var (
+	// acquireScript 获取锁并递增栅栏令牌，KEYS[1] 为锁，KEYS[2] 为栅栏令牌计数器
+	acquireScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0`)
+	// extendScript 持有者续期
+	extendScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`)
+	// releaseScript 持有者释放锁
+	releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+// redisBackend 基于 Redis 的锁存储，锁与栅栏令牌计数器使用相同的 hash tag，兼容 Redis Cluster
+type redisBackend struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisLocker 创建基于 Redis 的分布式锁，栅栏令牌计数器不会过期
+func NewRedisLocker(client *redis.Client) Locker {
+	return &locker{backend: &redisBackend{client: client, prefix: DefaultRedisPrefix}}
+}
+
+// NewRedisLockerFromURI 通过 persistence.NewRedisClient 创建 Redis 客户端以及分布式锁
+func NewRedisLockerFromURI(factory *factory.ObjectFactory, uri string) (Locker, error) {
+	client, err := persistence.NewRedisClient(factory, uri)
+	if err != nil {
+		return nil, err
+	}
+	return NewRedisLocker(client), nil
+}
+
+// keys 锁以及栅栏令牌计数器的 Key
+func (b *redisBackend) keys(key string) []string {
+	lockKey := b.prefix + "{" + key + "}"
+	return []string{lockKey, lockKey + ":fence"}
+}
+
+// acquire 获取锁
+func (b *redisBackend) acquire(ctx context.Context, key, token string, ttl time.Duration) (int64, bool, error) {
+	fence, err := acquireScript.Run(ctx, b.client, b.keys(key), token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, false, err
+	}
+	return fence, fence > 0, nil
+}
+
+// extend 续期
+func (b *redisBackend) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	result, err := extendScript.Run(ctx, b.client, b.keys(key)[:1], token, ttl.Milliseconds()).Int64()
+	return result == 1, err
+}
+
+// release 释放锁
+func (b *redisBackend) release(ctx context.Context, key, token string) (bool, error) {
+	result, err := releaseScript.Run(ctx, b.client, b.keys(key)[:1], token).Int64()
+	return result == 1, err
+}
@@ -0,0 +1,112 @@
+package migration
+
+import (
+	"context"
+	"ddd-demo/common/consts"
+	"ddd-demo/common/factory"
+	"ddd-demo/infrastructure/config"
+	"ddd-demo/infrastructure/persistence"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// commandUsage 迁移命令的用法
+const commandUsage = `usage:
+  migrate up [--to=VERSION] [--dry-run]   执行未执行的迁移，指定 --to 时只执行到该版本
+  migrate down [--steps=N] [--dry-run]    回滚最近执行的 N 个迁移，默认为 1
+  migrate status                          查看所有迁移的状态`
+
+// RunCommand 执行迁移命令，args 为 migrate 之后的参数。数据库连接使用 persistence.mysql 配置
+func RunCommand(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w\n%s", consts.ErrMigrationUnknownCommand, commandUsage)
+	}
+	if args[0] != "up" && args[0] != "down" && args[0] != "status" {
+		return fmt.Errorf("%w: %s\n%s", consts.ErrMigrationUnknownCommand, args[0], commandUsage)
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "只输出将要执行的 SQL")
+	to := flags.Int64("to", 0, "执行到的版本")
+	steps := flags.Int("steps", 1, "回滚的迁移数量")
+	// 其他参数（比如 --server.port、--profile）由配置处理
+	if err := flags.Parse(commandArgs(flags, args[1:])); err != nil {
+		return err
+	}
+	if args[0] == "down" && *steps < 1 {
+		return fmt.Errorf("%w: %d", consts.ErrMigrationInvalidSteps, *steps)
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	conf, err := config.NewYamlConfiguration()
+	if err != nil {
+		return err
+	}
+	objectFactory := factory.NewIsolatedObjectFactory()
+	defer objectFactory.Destroy()
+	db, err := persistence.NewMysqlClientFromConfig(objectFactory, conf)
+	if err != nil {
+		return err
+	}
+
+	migrator := NewMigrator(db.DB(), migrations)
+	migrator.DryRun = *dryRun
+	migrator.Out = out
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx, *to)
+	case "down":
+		return migrator.Down(ctx, *steps)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := ""
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(writer, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, status.Status, appliedAt)
+		}
+		return writer.Flush()
+	}
+	return nil
+}
+
+// commandArgs 从 args 中挑选出 flags 中定义的参数
+func commandArgs(flags *flag.FlagSet, args []string) []string {
+	var selected []string
+	for i := 0; i < len(args); i++ {
+		name := strings.TrimLeft(args[i], "-")
+		if index := strings.Index(name, "="); index >= 0 {
+			name = name[:index]
+		}
+		f := flags.Lookup(name)
+		if f == nil || !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		selected = append(selected, args[i])
+		// --to 3 形式的参数需要带上参数值
+		isBool := false
+		if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); ok {
+			isBool = boolFlag.IsBoolFlag()
+		}
+		if !strings.Contains(args[i], "=") && !isBool && i+1 < len(args) {
+			i++
+			selected = append(selected, args[i])
+		}
+	}
+	return selected
+}
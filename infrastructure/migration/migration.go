@@ -0,0 +1,160 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"ddd-demo/common/consts"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// embedded 编译进二进制的迁移文件
+//
+//go:embed sql
+var embedded embed.FS
+
+// fileNamePattern 迁移文件名的格式，比如 0001_create_users.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([\w-]+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的迁移
+type Migration struct {
+	// Version 版本号，按从小到大的顺序执行
+	Version int64
+	// Name 名称
+	Name string
+	// Up 升级的 SQL
+	Up string
+	// Down 回滚的 SQL，为空时不能回滚
+	Down string
+}
+
+// Checksum 升级 SQL 的校验和，用于发现已经执行的迁移被修改
+func (m *Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// String 迁移的描述，比如 0001_create_users
+func (m *Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Migrations 获取编译进二进制的迁移，即 sql 目录下的迁移文件
+func Migrations() ([]*Migration, error) {
+	fsys, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return Load(fsys)
+}
+
+// Load 读取 fsys 根目录下的迁移文件，按版本号从小到大排列，其他文件会被忽略
+func Load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	versionToMigration := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("%w: %s", consts.ErrMigrationInvalidName, entry.Name())
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", consts.ErrMigrationInvalidName, entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, found := versionToMigration[version]
+		if !found {
+			migration = &Migration{Version: version, Name: matches[2]}
+			versionToMigration[version] = migration
+		} else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("%w: %d", consts.ErrMigrationDuplicate, version)
+		}
+		if matches[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(versionToMigration))
+	for _, migration := range versionToMigration {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("%w: %s has no up file", consts.ErrMigrationInvalidName, migration)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// splitStatements 将 SQL 脚本拆分为多条语句，分号、引号中的内容以及注释会被正确处理，注释会被去掉
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		quote      rune
+	)
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		if quote != 0 {
+			current.WriteRune(c)
+			if c == '\\' && quote != '`' && i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			current.WriteRune(c)
+		case c == '#' || (c == '-' && i+2 < len(runes) && runes[i+1] == '-' && unicode.IsSpace(runes[i+2])):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			current.WriteRune('\n')
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			// 跳过结尾的 */
+			i++
+			current.WriteRune(' ')
+		case c == ';':
+			flush()
+		default:
+			current.WriteRune(c)
+		}
+	}
+	flush()
+	return statements
+}
@@ -0,0 +1,301 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"ddd-demo/common/consts"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// DefaultTable 默认的记录已执行迁移的表
+	DefaultTable = "schema_migrations"
+	// DefaultLockTimeout 默认的等待迁移锁的超时时间
+	DefaultLockTimeout = time.Minute
+)
+
+// mysqlErrTableNotExists MySQL 表不存在的错误码
+const mysqlErrTableNotExists = 1146
+
+// 迁移的状态
+const (
+	// StatusApplied 已执行
+	StatusApplied = "applied"
+	// StatusPending 未执行
+	StatusPending = "pending"
+	// StatusChecksumMismatched 已执行，但迁移文件在执行后被修改
+	StatusChecksumMismatched = "checksum mismatched"
+	// StatusMissingFile 已执行，但迁移文件不存在
+	StatusMissingFile = "missing file"
+)
+
+// Status 迁移的状态
+type Status struct {
+	Version   int64
+	Name      string
+	Status    string
+	AppliedAt *time.Time
+}
+
+// record schema_migrations 表中的一条记录
+type record struct {
+	version   int64
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrator MySQL 的迁移执行器。执行迁移前通过 GET_LOCK 获取数据库级别的锁，多个副本同时启动时只有一个执行迁移。
+// MySQL 的 DDL 会隐式提交，因此迁移不在事务中执行，一个迁移中途失败时需要人工处理
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+
+	// Table 记录已执行迁移的表
+	Table string
+	// LockTimeout 等待迁移锁的超时时间
+	LockTimeout time.Duration
+	// DryRun 为 true 时只输出将要执行的 SQL，不修改数据库
+	DryRun bool
+	// Out 输出执行过程以及 DryRun 时的 SQL
+	Out io.Writer
+}
+
+// NewMigrator 创建迁移执行器，db 可以通过 persistence.NewMysqlClient(...).DB() 获取
+func NewMigrator(db *sql.DB, migrations []*Migration) *Migrator {
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		Table:       DefaultTable,
+		LockTimeout: DefaultLockTimeout,
+		Out:         ioutil.Discard,
+	}
+}
+
+// Up 按版本号从小到大执行未执行的迁移，target 大于 0 时只执行到该版本
+func (m *Migrator) Up(ctx context.Context, target int64) error {
+	return m.withConn(ctx, func(conn *sql.Conn, applied map[int64]*record) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if target > 0 && migration.Version > target {
+				break
+			}
+			if _, found := applied[migration.Version]; found {
+				continue
+			}
+			fmt.Fprintf(m.Out, "-- migrate up %s\n", migration)
+			if err := m.exec(ctx, conn, migration.Up); err != nil {
+				return fmt.Errorf("migrate up %s: %w", migration, err)
+			}
+			if m.DryRun {
+				continue
+			}
+			_, err := conn.ExecContext(ctx,
+				"INSERT INTO `"+m.Table+"` (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				migration.Version, migration.Name, migration.Checksum(), time.Now())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down 按版本号从大到小回滚最近执行的 steps 个迁移，steps 小于 1 时返回 ErrMigrationInvalidSteps
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("%w: %d", consts.ErrMigrationInvalidSteps, steps)
+	}
+	return m.withConn(ctx, func(conn *sql.Conn, applied map[int64]*record) error {
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i] > versions[j]
+		})
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+
+		for _, version := range versions {
+			migration := m.find(version)
+			if migration == nil {
+				return fmt.Errorf("%w: %d_%s", consts.ErrMigrationMissingFile, version, applied[version].name)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %s", consts.ErrMigrationIrreversible, migration)
+			}
+			fmt.Fprintf(m.Out, "-- migrate down %s\n", migration)
+			if err := m.exec(ctx, conn, migration.Down); err != nil {
+				return fmt.Errorf("migrate down %s: %w", migration, err)
+			}
+			if m.DryRun {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM `"+m.Table+"` WHERE version = ?", version); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status 获取所有迁移的状态，按版本号从小到大排列
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := &Status{Version: migration.Version, Name: migration.Name, Status: StatusPending}
+		if r, found := applied[migration.Version]; found {
+			appliedAt := r.appliedAt
+			status.AppliedAt = &appliedAt
+			status.Status = StatusApplied
+			if r.checksum != migration.Checksum() {
+				status.Status = StatusChecksumMismatched
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	for version, r := range applied {
+		if m.find(version) == nil {
+			appliedAt := r.appliedAt
+			statuses = append(statuses, &Status{
+				Version:   version,
+				Name:      r.name,
+				Status:    StatusMissingFile,
+				AppliedAt: &appliedAt,
+			})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// withConn 在同一个连接上获取迁移锁、创建迁移表并执行 fn，DryRun 时不获取锁也不创建表
+func (m *Migrator) withConn(ctx context.Context, fn func(conn *sql.Conn, applied map[int64]*record) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if !m.DryRun {
+		if err := m.lock(ctx, conn); err != nil {
+			return err
+		}
+		defer m.unlock(conn)
+		_, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS `"+m.Table+"` ("+
+			"version BIGINT NOT NULL PRIMARY KEY, "+
+			"name VARCHAR(255) NOT NULL, "+
+			"checksum CHAR(64) NOT NULL, "+
+			"applied_at DATETIME NOT NULL"+
+			")")
+		if err != nil {
+			return err
+		}
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, applied)
+}
+
+// lock 获取迁移锁，锁属于连接，连接关闭时自动释放
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) error {
+	var locked sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(CONCAT(DATABASE(), '.', ?), ?)",
+		m.Table, int(m.LockTimeout/time.Second)).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return consts.ErrMigrationLockTimeout
+	}
+	return nil
+}
+
+// unlock 释放迁移锁
+func (m *Migrator) unlock(conn *sql.Conn) {
+	_, _ = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.', ?))", m.Table)
+}
+
+// applied 读取已执行的迁移，迁移表不存在时返回空
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]*record, error) {
+	applied := make(map[int64]*record)
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM `"+m.Table+"`")
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrTableNotExists {
+			return applied, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r := &record{}
+		var appliedAt mysql.NullTime
+		if err := rows.Scan(&r.version, &r.name, &r.checksum, &appliedAt); err != nil {
+			return nil, err
+		}
+		r.appliedAt = appliedAt.Time
+		applied[r.version] = r
+	}
+	return applied, rows.Err()
+}
+
+// verify 检查已执行的迁移文件是否被修改
+func (m *Migrator) verify(applied map[int64]*record) error {
+	for _, migration := range m.migrations {
+		if r, found := applied[migration.Version]; found && r.checksum != migration.Checksum() {
+			return fmt.Errorf("%w: %s", consts.ErrMigrationChecksum, migration)
+		}
+	}
+	return nil
+}
+
+// exec 逐条执行 SQL 脚本中的语句，DryRun 时只输出语句
+func (m *Migrator) exec(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, statement := range splitStatements(script) {
+		if m.DryRun {
+			fmt.Fprintf(m.Out, "%s;\n", statement)
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// find 根据版本号查找迁移
+func (m *Migrator) find(version int64) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+-- 事务性发件箱，由 infrastructure/outbox 写入并转发到 Kafka
+CREATE TABLE outbox (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    topic VARCHAR(255) NOT NULL,
+    message_key VARCHAR(255) NOT NULL DEFAULT '',
+    payload MEDIUMBLOB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME(3) NOT NULL,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at DATETIME(3) NOT NULL,
+    sent_at DATETIME(3) NULL,
+    PRIMARY KEY (id),
+    KEY idx_outbox_status_next_attempt_at (status, next_attempt_at, id),
+    KEY idx_outbox_message_key_status (message_key, status, id),
+    KEY idx_outbox_status_sent_at (status, sent_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
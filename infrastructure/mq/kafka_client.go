@@ -3,6 +3,7 @@ package mq
 import (
 	"context"
 	"ddd-demo/common"
+	"ddd-demo/common/factory"
 	"encoding/json"
 	"os"
 	"os/signal"
@@ -19,9 +20,14 @@ type KafkaClient struct {
 	brokers []string
 }
 
+// newConfig 创建客户端的基础配置，生产者、消费者以及 Ping 共用，版本、认证等配置在这里设置
+func (k *KafkaClient) newConfig() *sarama.Config {
+	return sarama.NewConfig()
+}
+
 // GetConsumerClint 获取消费者客户端
 func (k *KafkaClient) GetConsumerClint(group string) (sarama.ConsumerGroup, error) {
-	config := sarama.NewConfig()
+	config := k.newConfig()
 	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
 	config.Consumer.Return.Errors = true
 	config.Consumer.Offsets.Initial = sarama.OffsetNewest
@@ -100,7 +106,7 @@ func (k *KafkaClient) Consume(topic, group string, callback func(interface{})) e
 
 // GetProducerClient 获取生产者客户端
 func (k *KafkaClient) GetProducerClient() (sarama.SyncProducer, error) {
-	config := sarama.NewConfig()
+	config := k.newConfig()
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Return.Successes = true
 	config.Producer.Partitioner = sarama.NewHashPartitioner
@@ -108,6 +114,28 @@ func (k *KafkaClient) GetProducerClient() (sarama.SyncProducer, error) {
 	return producer, err
 }
 
+// Ping 检查是否可以连接到 Kafka 集群。sarama 不支持 ctx，因此按 ctx 的剩余时间设置每个 broker 的网络超时并且不重试，
+// 检查在 ctx 结束前后返回，不会留下后台协程
+func (k *KafkaClient) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	config := k.newConfig()
+	config.Metadata.Retry.Max = 0
+	if deadline, ok := ctx.Deadline(); ok && len(k.brokers) > 0 {
+		timeout := time.Until(deadline) / time.Duration(len(k.brokers))
+		if timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+		config.Net.DialTimeout, config.Net.ReadTimeout, config.Net.WriteTimeout = timeout, timeout, timeout
+	}
+	client, err := sarama.NewClient(k.brokers, config)
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
 // Produce 生产
 func (k *KafkaClient) Produce(topic string, message map[string]interface{}) error {
 	client, err := k.GetProducerClient()
@@ -136,19 +164,25 @@ func (k *KafkaClient) Produce(topic string, message map[string]interface{}) erro
 	return nil
 }
 
-var (
-	defaultKafkaClientOnce sync.Once
-	defaultKafkaClient     *KafkaClient
-)
+// Close 释放客户端。KafkaClient 不持有长连接，生产者与消费者由获取它们的调用方关闭
+func (k *KafkaClient) Close() error {
+	return nil
+}
 
-// NewKafkaClient 创建 KafkaClient 对象
-func NewKafkaClient(brokers []string) *KafkaClient {
-	defaultKafkaClientOnce.Do(func() {
-		defaultKafkaClient = &KafkaClient{
-			brokers: brokers,
-		}
+// NewKafkaClient 创建 KafkaClient 对象，相同的 brokers 共用一个对象，工厂销毁时关闭
+func NewKafkaClient(objectFactory *factory.ObjectFactory, brokers []string) (*KafkaClient, error) {
+	client, err := objectFactory.GetContext(context.Background(), "kafka://"+strings.Join(brokers, ","), &factory.Provider{
+		Create: func(_ context.Context, _ string) (interface{}, error) {
+			return &KafkaClient{brokers: brokers}, nil
+		},
+		Destroy: func(obj interface{}) error {
+			return obj.(*KafkaClient).Close()
+		},
 	})
-	return defaultKafkaClient
+	if err != nil {
+		return nil, err
+	}
+	return client.(*KafkaClient), nil
 }
 
 // Consumer 消费者结构体
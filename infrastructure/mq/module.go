@@ -0,0 +1,43 @@
+package mq
+
+import (
+	"ddd-demo/common/factory"
+	"ddd-demo/infrastructure/config"
+	"ddd-demo/infrastructure/health"
+
+	"go.uber.org/fx"
+)
+
+// KafkaConfigKey Kafka 的配置节
+const KafkaConfigKey = "mq.kafka"
+
+// KafkaConfig Kafka 的配置
+type KafkaConfig struct {
+	Brokers []string `mapstructure:"brokers" validate:"required,min=1,dive,required"`
+}
+
+// Module 根据 mq.kafka 配置提供 *KafkaClient，启动时检查是否可以连接到 Kafka 集群，同时提供就绪检查。
+// 客户端由 ObjectFactory 管理，依赖 factory.Module，应用停止时由工厂关闭
+var Module = fx.Module(
+	"kafka",
+	fx.Provide(
+		NewKafkaClientFromConfig,
+		fx.Annotate(func(client *KafkaClient) *health.Check {
+			return &health.Check{Name: "kafka", Probe: client.Ping}
+		}, fx.ResultTags(health.CheckGroup)),
+	),
+	fx.Invoke(func(lc fx.Lifecycle, client *KafkaClient) {
+		lc.Append(fx.Hook{
+			OnStart: client.Ping,
+		})
+	}),
+)
+
+// NewKafkaClientFromConfig 根据配置创建 KafkaClient 对象
+func NewKafkaClientFromConfig(factory *factory.ObjectFactory, conf config.Configuration) (*KafkaClient, error) {
+	kafkaConfig := &KafkaConfig{}
+	if err := conf.Unmarshal(KafkaConfigKey, kafkaConfig); err != nil {
+		return nil, err
+	}
+	return NewKafkaClient(factory, kafkaConfig.Brokers)
+}
@@ -0,0 +1,59 @@
+package outbox
+
+import (
+	"context"
+	"ddd-demo/infrastructure/config"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"go.uber.org/fx"
+)
+
+// ConfigKey 发件箱的配置节
+const ConfigKey = "outbox"
+
+// Config 发件箱转发器的配置
+type Config struct {
+	// BatchSize 每批锁定以及删除的消息数量
+	BatchSize int `mapstructure:"batchSize" default:"100" validate:"gte=1"`
+	// PollInterval 轮询发件箱的间隔
+	PollInterval time.Duration `mapstructure:"pollInterval" default:"1s" validate:"gt=0"`
+	// MaxAttempts 最多发送的次数，用尽后消息被标记为失败
+	MaxAttempts int `mapstructure:"maxAttempts" default:"10" validate:"gte=1"`
+	// BackoffBase 第一次失败后的重试等待时间，之后每次翻倍
+	BackoffBase time.Duration `mapstructure:"backoffBase" default:"1s" validate:"gt=0"`
+	// BackoffMax 最长的重试等待时间
+	BackoffMax time.Duration `mapstructure:"backoffMax" default:"5m" validate:"gtefield=BackoffBase"`
+	// Retention 已发送消息的保留时间
+	Retention time.Duration `mapstructure:"retention" default:"168h" validate:"gt=0"`
+	// PurgeInterval 删除过期消息的间隔
+	PurgeInterval time.Duration `mapstructure:"purgeInterval" default:"1h" validate:"gt=0"`
+}
+
+// Module 根据 outbox 配置提供 *Outbox 以及 *Relay，启动时开始转发消息，停止时等待转发完成并关闭生产者。
+// 需要同时引入 persistence.MysqlModule 与 mq.Module
+var Module = fx.Module(
+	"outbox",
+	fx.Provide(NewOutbox, NewKafkaPublisher, NewRelayFromConfig),
+	fx.Invoke(func(lc fx.Lifecycle, relay *Relay, publisher *KafkaPublisher) {
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				relay.Start()
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				relay.Stop()
+				return publisher.Close()
+			},
+		})
+	}),
+)
+
+// NewRelayFromConfig 根据配置创建通过 Kafka 发送消息的转发器
+func NewRelayFromConfig(db *gorm.DB, publisher *KafkaPublisher, conf config.Configuration) (*Relay, error) {
+	outboxConfig := &Config{}
+	if err := conf.Unmarshal(ConfigKey, outboxConfig); err != nil {
+		return nil, err
+	}
+	return NewRelay(db, publisher, outboxConfig), nil
+}
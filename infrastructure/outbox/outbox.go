@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"context"
+	"ddd-demo/infrastructure/persistence"
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TableName 发件箱表，由迁移 0001_create_outbox 创建
+const TableName = "outbox"
+
+// 消息的状态
+const (
+	// StatusPending 等待发送
+	StatusPending = "pending"
+	// StatusSent 已发送
+	StatusSent = "sent"
+	// StatusFailed 重试次数用尽，需要人工处理
+	StatusFailed = "failed"
+)
+
+// Message 发件箱中的消息
+type Message struct {
+	ID      uint64 `gorm:"primary_key"`
+	Topic   string
+	Key     string `gorm:"column:message_key"`
+	Payload []byte
+	Status  string
+	// Attempts 发送失败的次数
+	Attempts int
+	// NextAttemptAt 下一次发送的时间
+	NextAttemptAt time.Time
+	// LastError 最近一次发送失败的原因
+	LastError string
+	CreatedAt time.Time
+	SentAt    *time.Time
+}
+
+// TableName 发件箱表名
+func (Message) TableName() string {
+	return TableName
+}
+
+// Outbox 事务性发件箱，消息与聚合在同一个事务中写入，之后由 Relay 转发到 Kafka
+type Outbox struct {
+	db *gorm.DB
+}
+
+// NewOutbox 创建发件箱，db 需要与工作单元使用的 *gorm.DB 相同
+func NewOutbox(db *gorm.DB) *Outbox {
+	return &Outbox{db: db}
+}
+
+// Add 写入一条消息，ctx 中有 GormUnitOfWork 开启的事务时在该事务中写入。
+// payload 为 []byte 时原样发送，否则序列化为 JSON；key 相同的消息按写入顺序发送
+func (o *Outbox) Add(ctx context.Context, topic, key string, payload interface{}) error {
+	value, ok := payload.([]byte)
+	if !ok {
+		var err error
+		if value, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+	return persistence.GormFromContext(ctx, o.db).Create(&Message{
+		Topic:         topic,
+		Key:           key,
+		Payload:       value,
+		Status:        StatusPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
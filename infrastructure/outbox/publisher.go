@@ -0,0 +1,96 @@
+package outbox
+
+import (
+	"context"
+	"ddd-demo/infrastructure/mq"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+)
+
+// IDHeader 消息 ID 的 Kafka 消息头，Relay 保证至少发送一次，消费者可以据此去重
+const IDHeader = "outbox-id"
+
+// Publisher 发送发件箱中的消息。
+// Relay 在事务提交之前发送消息，发送成功但提交失败（或者 ctx 结束时消息已经发出）时消息会被再次发送，
+// 因此只保证至少发送一次，消费者需要根据 IDHeader 去重
+type Publisher interface {
+	// Publish 发送消息，需要在 ctx 结束时尽快返回
+	Publish(ctx context.Context, message *Message) error
+}
+
+// KafkaPublisher 通过 mq.KafkaClient 发送消息，复用同一个同步生产者
+type KafkaPublisher struct {
+	client *mq.KafkaClient
+
+	// sem 容量为 1，保护 producer 并且保证同一时刻只有一次发送，获取时可以响应 ctx 结束
+	sem      chan struct{}
+	producer sarama.SyncProducer
+}
+
+// NewKafkaPublisher 创建 Kafka 发送者
+func NewKafkaPublisher(client *mq.KafkaClient) *KafkaPublisher {
+	return &KafkaPublisher{client: client, sem: make(chan struct{}, 1)}
+}
+
+// Publish 发送消息，发送失败时关闭生产者，下次发送时重新创建。
+// ctx 结束时返回 ctx.Err()，已经开始的发送在后台完成，消息仍然可能被发出
+func (p *KafkaPublisher) Publish(ctx context.Context, message *Message) error {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := ctx.Err(); err != nil {
+		<-p.sem
+		return err
+	}
+	if p.producer == nil {
+		producer, err := p.client.GetProducerClient()
+		if err != nil {
+			<-p.sem
+			return err
+		}
+		p.producer = producer
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: message.Topic,
+		Value: sarama.ByteEncoder(message.Payload),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(IDHeader), Value: []byte(strconv.FormatUint(message.ID, 10))},
+		},
+		Timestamp: message.CreatedAt,
+	}
+	if message.Key != "" {
+		msg.Key = sarama.StringEncoder(message.Key)
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer func() { <-p.sem }()
+		_, _, err := p.producer.SendMessage(msg)
+		if err != nil {
+			_ = p.producer.Close()
+			p.producer = nil
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close 关闭生产者
+func (p *KafkaPublisher) Close() error {
+	p.sem <- struct{}{}
+	defer func() { <-p.sem }()
+	if p.producer == nil {
+		return nil
+	}
+	err := p.producer.Close()
+	p.producer = nil
+	return err
+}
@@ -0,0 +1,183 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// maxErrorLength last_error 列的最大长度
+const maxErrorLength = 1024
+
+// Relay 将发件箱中的消息转发到 Kafka。每批消息通过 SELECT ... FOR UPDATE SKIP LOCKED 加锁，
+// 多个副本同时运行时各自处理不同的消息。消息至少发送一次，发送失败时按指数退避重试，
+// key 相同的消息只有在更早的消息发送成功或者标记为失败后才会被选中，以保持发送顺序
+type Relay struct {
+	db        *gorm.DB
+	publisher Publisher
+	config    *Config
+
+	// mu 保护 stop 以及 done，未启动时为 nil
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRelay 创建转发器，db 需要连接主库（MySQL 8.0 及以上，支持 SKIP LOCKED）
+func NewRelay(db *gorm.DB, publisher Publisher, config *Config) *Relay {
+	return &Relay{db: db, publisher: publisher, config: config}
+}
+
+// RelayOnce 在一个事务中锁定并发送一批到期的消息，返回锁定的消息数量
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	tx := r.db.BeginTx(ctx, nil)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	now := time.Now()
+	var messages []*Message
+	err := tx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+		// 同一个 key 有更早的未发送消息时跳过，无论它是在等待重试还是正在被其他副本发送
+		Where("NOT EXISTS (SELECT 1 FROM "+TableName+" AS earlier WHERE earlier.message_key = "+TableName+".message_key"+
+			" AND earlier.message_key <> '' AND earlier.status = ? AND earlier.id < "+TableName+".id)", StatusPending).
+		Order("id").
+		Limit(r.config.BatchSize).
+		Find(&messages).Error
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// 每个 key 在一批中最多只有一条消息
+	for _, message := range messages {
+		var updates map[string]interface{}
+		if publishErr := r.publisher.Publish(ctx, message); publishErr != nil {
+			updates = r.failure(message, publishErr)
+		} else {
+			updates = map[string]interface{}{"status": StatusSent, "sent_at": time.Now()}
+		}
+		if err := tx.Model(message).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			return len(messages), err
+		}
+	}
+	return len(messages), tx.Commit().Error
+}
+
+// failure 发送失败时需要更新的列，重试次数用尽时标记为失败
+func (r *Relay) failure(message *Message, err error) map[string]interface{} {
+	attempts := message.Attempts + 1
+	lastError := []rune(err.Error())
+	if len(lastError) > maxErrorLength {
+		lastError = lastError[:maxErrorLength]
+	}
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"last_error":      string(lastError),
+		"next_attempt_at": time.Now().Add(r.backoff(attempts)),
+	}
+	if attempts >= r.config.MaxAttempts {
+		updates["status"] = StatusFailed
+	}
+	return updates
+}
+
+// backoff 第 attempts 次失败后的等待时间，从 BackoffBase 开始翻倍，不超过 BackoffMax
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.config.BackoffBase
+	for i := 1; i < attempts && delay < r.config.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > r.config.BackoffMax {
+		delay = r.config.BackoffMax
+	}
+	return delay
+}
+
+// Purge 删除发送时间早于 Retention 的已发送消息，返回删除的数量。失败的消息需要人工处理，不会被删除
+func (r *Relay) Purge(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-r.config.Retention)
+	var total int64
+	for {
+		result, err := r.db.DB().ExecContext(ctx, "DELETE FROM "+TableName+" WHERE status = ? AND sent_at < ? LIMIT ?",
+			StatusSent, before, r.config.BatchSize)
+		if err != nil {
+			return total, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += affected
+		if affected < int64(r.config.BatchSize) {
+			return total, nil
+		}
+	}
+}
+
+// Start 在后台每隔 PollInterval 转发一次消息，一批消息已满时立即转发下一批；每隔 PurgeInterval 删除过期的消息
+func (r *Relay) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	r.stop, r.done = stop, done
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+	go func() {
+		defer close(done)
+		poll := time.NewTicker(r.config.PollInterval)
+		defer poll.Stop()
+		purge := time.NewTicker(r.config.PurgeInterval)
+		defer purge.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-poll.C:
+				for {
+					count, err := r.RelayOnce(ctx)
+					if err != nil && ctx.Err() == nil {
+						fmt.Println("relay outbox error:", err)
+					}
+					if err != nil || count < r.config.BatchSize {
+						break
+					}
+				}
+			case <-purge.C:
+				if _, err := r.Purge(ctx); err != nil && ctx.Err() == nil {
+					fmt.Println("purge outbox error:", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止转发，并等待正在发送的一批消息完成
+func (r *Relay) Stop() {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,180 @@
+package persistence
+
+import (
+	"context"
+	"ddd-demo/common/consts"
+	"ddd-demo/domain/repository"
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// GormRepository 基于 gorm 的仓储实现，实体需要是 gorm 的模型，查询条件中的字段使用结构体字段名或者列名。
+// 查询使用 connector 的 Reader，保存与删除使用 Writer。通过 GormUnitOfWork 开启事务后，使用事务 ctx 的读写都在该事务中执行
+type GormRepository struct {
+	connector GormConnector
+	newEntity func() repository.Entity
+}
+
+// NewGormRepository 创建基于 gorm 的仓储，newEntity 用于创建实体，比如 func() repository.Entity { return &User{} }
+func NewGormRepository(db *gorm.DB, newEntity func() repository.Entity) repository.Repository {
+	return &GormRepository{connector: &singleGorm{db: db}, newEntity: newEntity}
+}
+
+// NewGormRepositoryWithConnector 创建读写分别使用 connector 提供的连接的仓储，比如读操作路由到只读副本的 *MysqlCluster
+func NewGormRepositoryWithConnector(connector GormConnector, newEntity func() repository.Entity) repository.Repository {
+	return &GormRepository{connector: connector, newEntity: newEntity}
+}
+
+// FindByID 根据主键查询实体
+func (r *GormRepository) FindByID(ctx context.Context, id interface{}, dest repository.Entity) error {
+	db := r.connector.Reader(ctx)
+	scope := db.NewScope(dest)
+	err := db.Where(scope.Quote(scope.PrimaryKey())+" = ?", id).First(dest).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return consts.ErrRepositoryNotFound
+	}
+	return err
+}
+
+// Save 保存实体，主键为零值时插入，否则更新，更新不到记录时插入
+func (r *GormRepository) Save(ctx context.Context, entity repository.Entity) error {
+	return r.connector.Writer(ctx).Save(entity).Error
+}
+
+// Delete 根据主键删除实体，模型包含 DeletedAt 字段时为软删除
+func (r *GormRepository) Delete(ctx context.Context, id interface{}) error {
+	db := r.connector.Writer(ctx)
+	entity := r.newEntity()
+	scope := db.NewScope(entity)
+	return db.Where(scope.Quote(scope.PrimaryKey())+" = ?", id).Delete(entity).Error
+}
+
+// FindBy 查询满足 spec 的实体
+func (r *GormRepository) FindBy(
+	ctx context.Context,
+	spec repository.Specification,
+	pageable *repository.PageRequest,
+	dest interface{},
+) (*repository.Page, error) {
+	db := r.connector.Reader(ctx)
+	entity := r.newEntity()
+	scope := db.NewScope(entity)
+	query := db.Model(entity)
+	if spec != nil {
+		expr, args, err := gormExpr(scope, spec)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(expr, args...)
+	}
+
+	var total int64
+	if pageable == nil || !pageable.SkipCount {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, err
+		}
+	}
+	if pageable != nil {
+		for _, order := range pageable.Sort {
+			column, err := gormColumn(scope, order.Field)
+			if err != nil {
+				return nil, err
+			}
+			if order.Desc {
+				column += " DESC"
+			}
+			query = query.Order(column)
+		}
+		if limit := pageable.Limit(); limit > 0 {
+			query = query.Offset(pageable.Offset()).Limit(limit)
+		}
+	}
+	if err := query.Find(dest).Error; err != nil {
+		return nil, err
+	}
+	return repository.NewPage(pageable, total), nil
+}
+
+// gormColumn 将字段名转换为转义后的列名
+func gormColumn(scope *gorm.Scope, name string) (string, error) {
+	field, found := scope.FieldByName(name)
+	if !found || field.IsIgnored {
+		return "", fmt.Errorf("%w: %s", consts.ErrRepositoryUnknownField, name)
+	}
+	return scope.Quote(field.DBName), nil
+}
+
+// gormExpr 将查询条件翻译为 SQL 条件表达式以及参数
+func gormExpr(scope *gorm.Scope, spec repository.Specification) (string, []interface{}, error) {
+	switch s := spec.(type) {
+	case *repository.Condition:
+		column, err := gormColumn(scope, s.Field)
+		if err != nil {
+			return "", nil, err
+		}
+		switch s.Operator {
+		case repository.OpEq:
+			if s.Value == nil {
+				return column + " IS NULL", nil, nil
+			}
+			return column + " = ?", []interface{}{s.Value}, nil
+		case repository.OpNe:
+			if s.Value == nil {
+				return column + " IS NOT NULL", nil, nil
+			}
+			// 与其他实现一致，空值也满足不等于
+			return column + " <> ? OR " + column + " IS NULL", []interface{}{s.Value}, nil
+		case repository.OpGt:
+			return column + " > ?", []interface{}{s.Value}, nil
+		case repository.OpGte:
+			return column + " >= ?", []interface{}{s.Value}, nil
+		case repository.OpLt:
+			return column + " < ?", []interface{}{s.Value}, nil
+		case repository.OpLte:
+			return column + " <= ?", []interface{}{s.Value}, nil
+		case repository.OpIn:
+			values, _ := s.Value.([]interface{})
+			if len(values) == 0 {
+				return "1 = 0", nil, nil
+			}
+			return column + " IN (?)", []interface{}{values}, nil
+		case repository.OpLike:
+			// 与排序规则无关，始终不区分大小写
+			return "LOWER(" + column + ") LIKE LOWER(?)", []interface{}{s.Value}, nil
+		}
+		return "", nil, fmt.Errorf("%w: operator %s", consts.ErrRepositoryUnsupported, s.Operator)
+	case *repository.Composite:
+		exprs := make([]string, 0, len(s.Specs))
+		var args []interface{}
+		for _, child := range s.Specs {
+			expr, childArgs, err := gormExpr(scope, child)
+			if err != nil {
+				return "", nil, err
+			}
+			exprs = append(exprs, "("+expr+")")
+			args = append(args, childArgs...)
+		}
+		switch s.Logic {
+		case repository.LogicAnd:
+			if len(exprs) == 0 {
+				return "1 = 1", nil, nil
+			}
+			return strings.Join(exprs, " AND "), args, nil
+		case repository.LogicOr:
+			if len(exprs) == 0 {
+				return "1 = 0", nil, nil
+			}
+			return strings.Join(exprs, " OR "), args, nil
+		case repository.LogicNot:
+			if len(exprs) != 1 {
+				return "", nil, fmt.Errorf("%w: not requires exactly one specification", consts.ErrRepositoryUnsupported)
+			}
+			// 空值参与比较的结果为 NULL，NOT 之后仍然为 NULL，与其他实现一致按不满足处理后再取反
+			return "NOT COALESCE(" + exprs[0] + ", FALSE)", args, nil
+		}
+		return "", nil, fmt.Errorf("%w: logic %s", consts.ErrRepositoryUnsupported, s.Logic)
+	}
+	return "", nil, fmt.Errorf("%w: %T", consts.ErrRepositoryUnsupported, spec)
+}
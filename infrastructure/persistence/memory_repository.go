@@ -0,0 +1,461 @@
+package persistence
+
+import (
+	"context"
+	"ddd-demo/common/consts"
+	"ddd-demo/common/serializer"
+	"ddd-demo/domain/repository"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository 基于内存的仓储实现，用于在没有数据库时测试领域服务。
+// 实体使用 gob 序列化后保存，读写的都是副本，实体的 ID 不能为零值。查询条件中的字段使用结构体字段名
+type MemoryRepository struct {
+	newEntity func() repository.Entity
+
+	mu sync.RWMutex
+	// keys 按插入顺序排列的 ID，未指定排序条件时按该顺序返回
+	keys []string
+	// items ID 到序列化后的实体的映射
+	items map[string][]byte
+}
+
+// NewMemoryRepository 创建基于内存的仓储，newEntity 用于创建实体，比如 func() repository.Entity { return &User{} }
+func NewMemoryRepository(newEntity func() repository.Entity) repository.Repository {
+	return &MemoryRepository{
+		newEntity: newEntity,
+		items:     make(map[string][]byte),
+	}
+}
+
+// memoryKey 将 ID 转换为字符串，使 int 与 int64 等不同类型的相同 ID 对应同一个实体
+func memoryKey(id interface{}) string {
+	return fmt.Sprint(id)
+}
+
+// FindByID 根据 ID 查询实体
+func (r *MemoryRepository) FindByID(_ context.Context, id interface{}, dest repository.Entity) error {
+	r.mu.RLock()
+	buf, found := r.items[memoryKey(id)]
+	r.mu.RUnlock()
+	if !found {
+		return consts.ErrRepositoryNotFound
+	}
+	return serializer.GobDecode(dest, buf)
+}
+
+// Save 保存实体的副本
+func (r *MemoryRepository) Save(_ context.Context, entity repository.Entity) error {
+	id := entity.Identity()
+	if id == nil || reflect.ValueOf(id).IsZero() {
+		return consts.ErrRepositoryMissingID
+	}
+	buf, err := serializer.GobEncode(entity)
+	if err != nil {
+		return err
+	}
+	key := memoryKey(id)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, found := r.items[key]; !found {
+		r.keys = append(r.keys, key)
+	}
+	r.items[key] = buf
+	return nil
+}
+
+// Delete 根据 ID 删除实体
+func (r *MemoryRepository) Delete(_ context.Context, id interface{}) error {
+	key := memoryKey(id)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, found := r.items[key]; !found {
+		return nil
+	}
+	delete(r.items, key)
+	for i, k := range r.keys {
+		if k == key {
+			r.keys = append(r.keys[:i], r.keys[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// FindBy 查询满足 spec 的实体
+func (r *MemoryRepository) FindBy(
+	_ context.Context,
+	spec repository.Specification,
+	pageable *repository.PageRequest,
+	dest interface{},
+) (*repository.Page, error) {
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		return nil, consts.ErrRepositoryInvalidDest
+	}
+	// 在比较之前检查排序字段，没有实体时也返回与其他实现一致的错误
+	if pageable != nil {
+		probe := r.newEntity()
+		for _, order := range pageable.Sort {
+			if _, err := memoryField(probe, order.Field); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	r.mu.RLock()
+	entities := make([]repository.Entity, 0, len(r.keys))
+	for _, key := range r.keys {
+		entity := r.newEntity()
+		if err := serializer.GobDecode(entity, r.items[key]); err != nil {
+			r.mu.RUnlock()
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+	r.mu.RUnlock()
+
+	matched := entities[:0]
+	for _, entity := range entities {
+		ok := true
+		if spec != nil {
+			var err error
+			if ok, err = memoryMatch(entity, spec); err != nil {
+				return nil, err
+			}
+		}
+		if ok {
+			matched = append(matched, entity)
+		}
+	}
+
+	if pageable != nil && len(pageable.Sort) > 0 {
+		sort.SliceStable(matched, func(i, j int) bool {
+			for _, order := range pageable.Sort {
+				a, _ := memoryField(matched[i], order.Field)
+				b, _ := memoryField(matched[j], order.Field)
+				result, _ := compareValues(a, b)
+				if result == 0 {
+					continue
+				}
+				return (result < 0) != order.Desc
+			}
+			return false
+		})
+	}
+
+	total := int64(len(matched))
+	if pageable != nil && pageable.SkipCount {
+		total = 0
+	}
+	if limit := pageable.Limit(); limit > 0 {
+		start := pageable.Offset()
+		if start > len(matched) {
+			start = len(matched)
+		}
+		end := start + limit
+		if end > len(matched) {
+			end = len(matched)
+		}
+		matched = matched[start:end]
+	}
+
+	slice := destValue.Elem()
+	elemType := slice.Type().Elem()
+	for _, entity := range matched {
+		value := reflect.ValueOf(entity)
+		if value.Type() != elemType {
+			if value.Kind() != reflect.Ptr || value.Elem().Type() != elemType {
+				return nil, consts.ErrRepositoryInvalidDest
+			}
+			value = value.Elem()
+		}
+		slice = reflect.Append(slice, value)
+	}
+	destValue.Elem().Set(slice)
+	return repository.NewPage(pageable, total), nil
+}
+
+// memoryField 获取实体的字段值，字段名不区分大小写
+func memoryField(entity interface{}, name string) (interface{}, error) {
+	value := reflect.ValueOf(entity)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Struct {
+		field := value.FieldByNameFunc(func(fieldName string) bool {
+			return strings.EqualFold(fieldName, name)
+		})
+		if field.IsValid() && field.CanInterface() {
+			return field.Interface(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", consts.ErrRepositoryUnknownField, name)
+}
+
+// memoryMatch 实体是否满足查询条件
+func memoryMatch(entity interface{}, spec repository.Specification) (bool, error) {
+	switch s := spec.(type) {
+	case *repository.Condition:
+		value, err := memoryField(entity, s.Field)
+		if err != nil {
+			return false, err
+		}
+		switch s.Operator {
+		case repository.OpIn:
+			values, _ := s.Value.([]interface{})
+			for _, candidate := range values {
+				if result, ok := compareValues(value, candidate); ok && result == 0 {
+					return true, nil
+				}
+			}
+			return false, nil
+		case repository.OpLike:
+			pattern, isString := s.Value.(string)
+			text, isText := indirect(value).(string)
+			if !isString {
+				return false, fmt.Errorf("%w: like pattern must be string", consts.ErrRepositoryUnsupported)
+			}
+			if !isText {
+				return false, nil
+			}
+			return regexp.MustCompile("(?is)" + likeRegexp(pattern)).MatchString(text), nil
+		}
+		result, ok := compareValues(value, s.Value)
+		switch s.Operator {
+		case repository.OpEq:
+			return ok && result == 0, nil
+		case repository.OpNe:
+			return !ok || result != 0, nil
+		case repository.OpGt:
+			return ok && result > 0, nil
+		case repository.OpGte:
+			return ok && result >= 0, nil
+		case repository.OpLt:
+			return ok && result < 0, nil
+		case repository.OpLte:
+			return ok && result <= 0, nil
+		}
+		return false, fmt.Errorf("%w: operator %s", consts.ErrRepositoryUnsupported, s.Operator)
+	case *repository.Composite:
+		switch s.Logic {
+		case repository.LogicAnd, repository.LogicOr:
+			// And 遇到不满足的条件即可返回，Or 遇到满足的条件即可返回
+			for _, child := range s.Specs {
+				ok, err := memoryMatch(entity, child)
+				if err != nil {
+					return false, err
+				}
+				if ok == (s.Logic == repository.LogicOr) {
+					return ok, nil
+				}
+			}
+			return s.Logic == repository.LogicAnd, nil
+		case repository.LogicNot:
+			if len(s.Specs) != 1 {
+				return false, fmt.Errorf("%w: not requires exactly one specification", consts.ErrRepositoryUnsupported)
+			}
+			ok, err := memoryMatch(entity, s.Specs[0])
+			return !ok, err
+		}
+		return false, fmt.Errorf("%w: logic %s", consts.ErrRepositoryUnsupported, s.Logic)
+	}
+	return false, fmt.Errorf("%w: %T", consts.ErrRepositoryUnsupported, spec)
+}
+
+// compareValues 比较两个值，a 小于、等于、大于 b 时分别返回 -1、0、1。
+// 支持数字（不同的数字类型之间也可以比较）、字符串、布尔值、时间以及相同类型的数组，指针比较指向的值，无法比较时 ok 为 false
+func compareValues(a, b interface{}) (result int, ok bool) {
+	a, b = indirect(a), indirect(b)
+	if ta, isTime := a.(time.Time); isTime {
+		tb, isTime := b.(time.Time)
+		if !isTime {
+			return 0, false
+		}
+		switch {
+		case ta.Before(tb):
+			return -1, true
+		case ta.After(tb):
+			return 1, true
+		}
+		return 0, true
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		if !va.IsValid() && !vb.IsValid() {
+			return 0, true
+		}
+		return 0, false
+	}
+	if isNumber(va) {
+		if !isNumber(vb) {
+			return 0, false
+		}
+		return compareNumbers(va, vb), true
+	}
+	switch va.Kind() {
+	case reflect.String:
+		if vb.Kind() != reflect.String {
+			return 0, false
+		}
+		return strings.Compare(va.String(), vb.String()), true
+	case reflect.Bool:
+		if vb.Kind() != reflect.Bool {
+			return 0, false
+		}
+		if va.Bool() == vb.Bool() {
+			return 0, true
+		}
+		if !va.Bool() {
+			return -1, true
+		}
+		return 1, true
+	case reflect.Array:
+		// 相同类型的数组（比如 ObjectID）按元素逐个比较
+		if va.Type() != vb.Type() {
+			return 0, false
+		}
+		for i := 0; i < va.Len(); i++ {
+			if result, ok := compareValues(va.Index(i).Interface(), vb.Index(i).Interface()); !ok || result != 0 {
+				return result, ok
+			}
+		}
+		return 0, true
+	}
+	if reflect.DeepEqual(a, b) {
+		return 0, true
+	}
+	return 0, false
+}
+
+// indirect 获取指针指向的值，空指针返回 nil
+func indirect(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr {
+		return value
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+// likeRegexp 将 SQL LIKE 模式转换为完整匹配的正则表达式，% 匹配任意多个字符，_ 匹配一个字符，\ 转义下一个字符
+func likeRegexp(pattern string) string {
+	var builder strings.Builder
+	builder.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '\\' && i+1 < len(runes):
+			i++
+			builder.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case c == '%':
+			builder.WriteString(".*")
+		case c == '_':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	builder.WriteString("$")
+	return builder.String()
+}
+
+// isNumber 是否为数字
+func isNumber(value reflect.Value) bool {
+	return isInt(value) || isUint(value) || isFloat(value)
+}
+
+func isInt(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isFloat(value reflect.Value) bool {
+	return value.Kind() == reflect.Float32 || value.Kind() == reflect.Float64
+}
+
+// compareNumbers 比较两个数字，整数之间精确比较，只有存在浮点数时才转换为 float64 比较
+func compareNumbers(a, b reflect.Value) int {
+	switch {
+	case isFloat(a) || isFloat(b):
+		return compareFloat(toFloat(a), toFloat(b))
+	case isInt(a) && isInt(b):
+		return compareInt(a.Int(), b.Int())
+	case isUint(a) && isUint(b):
+		return compareUint(a.Uint(), b.Uint())
+	case isInt(a):
+		// 负数小于任何无符号整数
+		if a.Int() < 0 {
+			return -1
+		}
+		return compareUint(uint64(a.Int()), b.Uint())
+	}
+	if b.Int() < 0 {
+		return 1
+	}
+	return compareUint(a.Uint(), uint64(b.Int()))
+}
+
+// toFloat 将数字转换为 float64
+func toFloat(value reflect.Value) float64 {
+	switch {
+	case isInt(value):
+		return float64(value.Int())
+	case isUint(value):
+		return float64(value.Uint())
+	}
+	return value.Float()
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
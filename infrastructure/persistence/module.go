@@ -0,0 +1,167 @@
+package persistence
+
+import (
+	"context"
+	"ddd-demo/common/factory"
+	"ddd-demo/infrastructure/config"
+	"ddd-demo/infrastructure/health"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jinzhu/gorm"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.uber.org/fx"
+)
+
+// 各客户端的配置节
+const (
+	MysqlConfigKey   = "persistence.mysql"
+	MongoDBConfigKey = "persistence.mongodb"
+	RedisConfigKey   = "persistence.redis"
+)
+
+// MysqlConfig MySQL 的配置，URI 为主库
+type MysqlConfig struct {
+	URI          string `mapstructure:"uri" validate:"required"`
+	MaxOpenConns int    `mapstructure:"maxOpenConns" default:"100" validate:"gte=0"`
+	MaxIdleConns int    `mapstructure:"maxIdleConns" default:"10" validate:"gte=0"`
+	// Replicas 只读副本的 URI
+	Replicas []string `mapstructure:"replicas" validate:"dive,required"`
+	// Balancer 副本的负载均衡策略，round_robin 或者 least_latency
+	Balancer string `mapstructure:"balancer" default:"round_robin" validate:"oneof=round_robin least_latency"`
+	// StickyWindow 写操作之后该时长内，同一请求的读操作使用主库
+	StickyWindow time.Duration `mapstructure:"stickyWindow" default:"5s" validate:"gte=0"`
+	// HealthCheckInterval 副本健康检查的间隔
+	HealthCheckInterval time.Duration `mapstructure:"healthCheckInterval" default:"5s" validate:"gt=0"`
+	// FailureThreshold 副本连续健康检查失败该次数后被剔除
+	FailureThreshold int `mapstructure:"failureThreshold" default:"3" validate:"gte=1"`
+}
+
+// MongoDBConfig MongoDB 的配置
+type MongoDBConfig struct {
+	URI      string `mapstructure:"uri" validate:"required"`
+	Database string `mapstructure:"database" validate:"required"`
+}
+
+// RedisConfig Redis 的配置
+type RedisConfig struct {
+	URI string `mapstructure:"uri" validate:"required"`
+}
+
+// MysqlModule 根据 persistence.mysql 配置提供 *MysqlCluster 以及主库的 *gorm.DB，
+// 启动时检查主库是否可以连接并开始副本的健康检查，同时提供主库的就绪检查
+var MysqlModule = fx.Module(
+	"mysql",
+	fx.Provide(
+		NewMysqlClusterFromConfig,
+		func(cluster *MysqlCluster) *gorm.DB {
+			return cluster.Primary()
+		},
+		fx.Annotate(func(cluster *MysqlCluster) *health.Check {
+			return &health.Check{Name: "mysql", Probe: cluster.Ping}
+		}, fx.ResultTags(health.CheckGroup)),
+	),
+	fx.Invoke(func(lc fx.Lifecycle, cluster *MysqlCluster) {
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				if err := cluster.Ping(ctx); err != nil {
+					return err
+				}
+				cluster.StartHealthCheck()
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				cluster.StopHealthCheck()
+				return nil
+			},
+		})
+	}),
+)
+
+// MongoDBModule 根据 persistence.mongodb 配置提供 *mongo.Client 以及 *mongo.Database，启动时检查是否可以连接，
+// 同时提供就绪检查
+var MongoDBModule = fx.Module(
+	"mongodb",
+	fx.Provide(
+		NewMongoDBClientFromConfig,
+		NewMongoDBDatabaseFromConfig,
+		fx.Annotate(func(client *mongo.Client) *health.Check {
+			return &health.Check{Name: "mongodb", Probe: func(ctx context.Context) error {
+				return client.Ping(ctx, readpref.Primary())
+			}}
+		}, fx.ResultTags(health.CheckGroup)),
+	),
+	fx.Invoke(func(lc fx.Lifecycle, client *mongo.Client) {
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				return client.Ping(ctx, readpref.Primary())
+			},
+		})
+	}),
+)
+
+// RedisModule 根据 persistence.redis 配置提供 *redis.Client，启动时检查是否可以连接，同时提供就绪检查
+var RedisModule = fx.Module(
+	"redis",
+	fx.Provide(
+		NewRedisClientFromConfig,
+		fx.Annotate(func(client *redis.Client) *health.Check {
+			return &health.Check{Name: "redis", Probe: func(ctx context.Context) error {
+				return client.Ping(ctx).Err()
+			}}
+		}, fx.ResultTags(health.CheckGroup)),
+	),
+	fx.Invoke(func(lc fx.Lifecycle, client *redis.Client) {
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				return client.Ping(ctx).Err()
+			},
+		})
+	}),
+)
+
+// NewMysqlClientFromConfig 根据配置创建 Mysql 客户端，非 prod Profile 下会打印 SQL
+func NewMysqlClientFromConfig(factory *factory.ObjectFactory, conf config.Configuration) (*gorm.DB, error) {
+	mysqlConfig := &MysqlConfig{}
+	if err := conf.Unmarshal(MysqlConfigKey, mysqlConfig); err != nil {
+		return nil, err
+	}
+	return NewMysqlClient(factory, mysqlConfig.URI, conf.Profile(), mysqlConfig.MaxOpenConns, mysqlConfig.MaxIdleConns)
+}
+
+// NewMysqlClusterFromConfig 根据配置创建 MySQL 主库以及只读副本的客户端
+func NewMysqlClusterFromConfig(factory *factory.ObjectFactory, conf config.Configuration) (*MysqlCluster, error) {
+	mysqlConfig := &MysqlConfig{}
+	if err := conf.Unmarshal(MysqlConfigKey, mysqlConfig); err != nil {
+		return nil, err
+	}
+	return NewMysqlCluster(factory, mysqlConfig, conf.Profile())
+}
+
+// NewMongoDBClientFromConfig 根据配置创建 MongoDB 客户端
+func NewMongoDBClientFromConfig(factory *factory.ObjectFactory, conf config.Configuration) (*mongo.Client, error) {
+	mongoDBConfig := &MongoDBConfig{}
+	if err := conf.Unmarshal(MongoDBConfigKey, mongoDBConfig); err != nil {
+		return nil, err
+	}
+	return NewMongoDBClient(factory, mongoDBConfig.URI)
+}
+
+// NewMongoDBDatabaseFromConfig 获取配置中指定的 MongoDB 数据库
+func NewMongoDBDatabaseFromConfig(client *mongo.Client, conf config.Configuration) (*mongo.Database, error) {
+	mongoDBConfig := &MongoDBConfig{}
+	if err := conf.Unmarshal(MongoDBConfigKey, mongoDBConfig); err != nil {
+		return nil, err
+	}
+	return client.Database(mongoDBConfig.Database), nil
+}
+
+// NewRedisClientFromConfig 根据配置创建 Redis 客户端
+func NewRedisClientFromConfig(factory *factory.ObjectFactory, conf config.Configuration) (*redis.Client, error) {
+	redisConfig := &RedisConfig{}
+	if err := conf.Unmarshal(RedisConfigKey, redisConfig); err != nil {
+		return nil, err
+	}
+	return NewRedisClient(factory, redisConfig.URI)
+}
@@ -11,22 +11,40 @@ import (
 
 // GetMongoDBCollection 获取 MongoDB 集合
 func GetMongoDBCollection(factory *factory.ObjectFactory, uri, databaseName, collectionName string) (*mongo.Collection, error) {
-	client, err := factory.Get(
-		uri,
-		func(uri string) (interface{}, error) {
+	client, err := NewMongoDBClient(factory, uri)
+	if err != nil {
+		return nil, err
+	}
+	return client.Database(databaseName).Collection(collectionName), nil
+}
+
+// NewMongoDBClient 创建 MongoDB 客户端
+func NewMongoDBClient(factory *factory.ObjectFactory, uri string) (*mongo.Client, error) {
+	return NewMongoDBClientContext(context.Background(), factory, uri)
+}
+
+// NewMongoDBClientContext 创建 MongoDB 客户端，ctx 结束时不再等待。
+// 工厂的健康检查通过 Ping 报告客户端是否可用，不会关闭已经注入的客户端
+func NewMongoDBClientContext(ctx context.Context, objectFactory *factory.ObjectFactory, uri string) (*mongo.Client, error) {
+	client, err := objectFactory.GetContext(ctx, uri, &factory.Provider{
+		Create: func(ctx context.Context, uri string) (interface{}, error) {
 			opts := options.Client()
 			opts.Monitor = otelmongo.NewMonitor()
-			return mongo.Connect(context.Background(), opts.ApplyURI(uri))
+			return mongo.Connect(ctx, opts.ApplyURI(uri))
 		},
-		func(obj interface{}) error {
+		Destroy: func(obj interface{}) error {
 			client, ok := obj.(*mongo.Client)
 			if !ok {
 				return nil
 			}
 			return client.Disconnect(context.Background())
-		})
+		},
+		Check: func(ctx context.Context, obj interface{}) error {
+			return obj.(*mongo.Client).Ping(ctx, nil)
+		},
+	})
 	if err != nil {
 		return nil, err
 	}
-	return client.(*mongo.Client).Database(databaseName).Collection(collectionName), nil
+	return client.(*mongo.Client), nil
 }
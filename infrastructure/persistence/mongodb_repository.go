@@ -0,0 +1,204 @@
+package persistence
+
+import (
+	"context"
+	"ddd-demo/common/consts"
+	"ddd-demo/domain/repository"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoDBRepository 基于 MongoDB 的仓储实现，实体的 ID 字段需要使用 bson:"_id" tag。
+// 查询条件中的字段使用结构体字段名或者 bson 字段名，包含 . 的嵌套字段直接使用。
+// 通过 MongoDBUnitOfWork 开启事务后，使用事务 ctx 的读写都在该事务中执行
+type MongoDBRepository struct {
+	collection *mongo.Collection
+	newEntity  func() repository.Entity
+}
+
+// NewMongoDBRepository 创建基于 MongoDB 的仓储，newEntity 用于创建实体，比如 func() repository.Entity { return &User{} }
+func NewMongoDBRepository(collection *mongo.Collection, newEntity func() repository.Entity) repository.Repository {
+	return &MongoDBRepository{collection: collection, newEntity: newEntity}
+}
+
+// FindByID 根据 _id 查询实体
+func (r *MongoDBRepository) FindByID(ctx context.Context, id interface{}, dest repository.Entity) error {
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(dest)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return consts.ErrRepositoryNotFound
+	}
+	return err
+}
+
+// Save 使用 _id 替换文档，不存在时插入
+func (r *MongoDBRepository) Save(ctx context.Context, entity repository.Entity) error {
+	id := entity.Identity()
+	if id == nil {
+		return consts.ErrRepositoryMissingID
+	}
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": id}, entity, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Delete 根据 _id 删除文档
+func (r *MongoDBRepository) Delete(ctx context.Context, id interface{}) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// FindBy 查询满足 spec 的实体
+func (r *MongoDBRepository) FindBy(
+	ctx context.Context,
+	spec repository.Specification,
+	pageable *repository.PageRequest,
+	dest interface{},
+) (*repository.Page, error) {
+	entityType := reflect.TypeOf(r.newEntity())
+	filter := bson.D{}
+	if spec != nil {
+		var err error
+		if filter, err = mongoFilter(entityType, spec); err != nil {
+			return nil, err
+		}
+	}
+
+	var total int64
+	if pageable == nil || !pageable.SkipCount {
+		var err error
+		if total, err = r.collection.CountDocuments(ctx, filter); err != nil {
+			return nil, err
+		}
+	}
+	findOptions := options.Find()
+	if pageable != nil {
+		if len(pageable.Sort) > 0 {
+			sort := bson.D{}
+			for _, order := range pageable.Sort {
+				field, err := mongoField(entityType, order.Field)
+				if err != nil {
+					return nil, err
+				}
+				direction := 1
+				if order.Desc {
+					direction = -1
+				}
+				sort = append(sort, bson.E{Key: field, Value: direction})
+			}
+			findOptions.SetSort(sort)
+		}
+		if limit := pageable.Limit(); limit > 0 {
+			findOptions.SetSkip(int64(pageable.Offset())).SetLimit(int64(limit))
+		}
+	}
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, dest); err != nil {
+		return nil, err
+	}
+	return repository.NewPage(pageable, total), nil
+}
+
+// mongoField 将字段名转换为 bson 字段名，未指定 bson tag 的字段使用小写的字段名，与 mongo-driver 保持一致
+func mongoField(entityType reflect.Type, name string) (string, error) {
+	if strings.Contains(name, ".") {
+		return name, nil
+	}
+	for entityType.Kind() == reflect.Ptr {
+		entityType = entityType.Elem()
+	}
+	if entityType.Kind() == reflect.Struct {
+		for i := 0; i < entityType.NumField(); i++ {
+			field := entityType.Field(i)
+			bsonName := strings.SplitN(field.Tag.Get("bson"), ",", 2)[0]
+			if bsonName == "-" {
+				continue
+			}
+			if bsonName == "" {
+				bsonName = strings.ToLower(field.Name)
+			}
+			if strings.EqualFold(field.Name, name) || bsonName == name {
+				return bsonName, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s", consts.ErrRepositoryUnknownField, name)
+}
+
+// mongoOperators 查询条件的运算符到 MongoDB 查询运算符的映射
+var mongoOperators = map[repository.Operator]string{
+	repository.OpNe:  "$ne",
+	repository.OpGt:  "$gt",
+	repository.OpGte: "$gte",
+	repository.OpLt:  "$lt",
+	repository.OpLte: "$lte",
+	repository.OpIn:  "$in",
+}
+
+// mongoFilter 将查询条件翻译为 MongoDB 的查询文档
+func mongoFilter(entityType reflect.Type, spec repository.Specification) (bson.D, error) {
+	switch s := spec.(type) {
+	case *repository.Condition:
+		field, err := mongoField(entityType, s.Field)
+		if err != nil {
+			return nil, err
+		}
+		switch s.Operator {
+		case repository.OpEq:
+			return bson.D{{Key: field, Value: s.Value}}, nil
+		case repository.OpLike:
+			pattern, ok := s.Value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: like pattern must be string", consts.ErrRepositoryUnsupported)
+			}
+			regex := primitive.Regex{Pattern: likeRegexp(pattern), Options: "is"}
+			return bson.D{{Key: field, Value: regex}}, nil
+		}
+		if operator, found := mongoOperators[s.Operator]; found {
+			value := s.Value
+			if values, isSlice := value.([]interface{}); isSlice && values == nil {
+				// 空切片会被编码为 null
+				value = bson.A{}
+			}
+			return bson.D{{Key: field, Value: bson.D{{Key: operator, Value: value}}}}, nil
+		}
+		return nil, fmt.Errorf("%w: operator %s", consts.ErrRepositoryUnsupported, s.Operator)
+	case *repository.Composite:
+		filters := make(bson.A, 0, len(s.Specs))
+		for _, child := range s.Specs {
+			filter, err := mongoFilter(entityType, child)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+		switch s.Logic {
+		case repository.LogicAnd:
+			if len(filters) == 0 {
+				return bson.D{}, nil
+			}
+			return bson.D{{Key: "$and", Value: filters}}, nil
+		case repository.LogicOr:
+			if len(filters) == 0 {
+				// $or 不能为空，使用永远不成立的条件
+				return bson.D{{Key: "_id", Value: bson.D{{Key: "$exists", Value: false}}}}, nil
+			}
+			return bson.D{{Key: "$or", Value: filters}}, nil
+		case repository.LogicNot:
+			if len(filters) != 1 {
+				return nil, fmt.Errorf("%w: not requires exactly one specification", consts.ErrRepositoryUnsupported)
+			}
+			return bson.D{{Key: "$nor", Value: filters}}, nil
+		}
+		return nil, fmt.Errorf("%w: logic %s", consts.ErrRepositoryUnsupported, s.Logic)
+	}
+	return nil, fmt.Errorf("%w: %T", consts.ErrRepositoryUnsupported, spec)
+}
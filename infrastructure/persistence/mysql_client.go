@@ -1,6 +1,8 @@
 package persistence
 
 import (
+	"context"
+	"database/sql"
 	"ddd-demo/common/factory"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -9,19 +11,33 @@ import (
 
 // NewMysqlClient 创建 Mysql 客户端
 func NewMysqlClient(factory *factory.ObjectFactory, uri, env string, maxOpenConns, maxIdleConns int) (*gorm.DB, error) {
-	client, err := factory.Get(
-		uri,
-		func(uri string) (interface{}, error) {
-			client, err := gorm.Open("mysql", uri)
+	return NewMysqlClientContext(context.Background(), factory, uri, env, maxOpenConns, maxIdleConns)
+}
+
+// NewMysqlClientContext 创建 Mysql 客户端，ctx 结束时不再等待连接。
+// 工厂的健康检查通过 Ping 报告客户端是否可用，不会关闭已经注入的客户端
+func NewMysqlClientContext(ctx context.Context, objectFactory *factory.ObjectFactory, uri, env string,
+	maxOpenConns, maxIdleConns int) (*gorm.DB, error) {
+	client, err := objectFactory.GetContext(ctx, uri, &factory.Provider{
+		Create: func(ctx context.Context, uri string) (interface{}, error) {
+			db, err := sql.Open("mysql", uri)
+			if err != nil {
+				return nil, err
+			}
+			if err := db.PingContext(ctx); err != nil {
+				_ = db.Close()
+				return nil, err
+			}
+			client, err := gorm.Open("mysql", db)
 			if err != nil {
-				panic(err)
+				return nil, err
 			} else if client.Error != nil {
-				panic(client.Error)
+				return nil, client.Error
 			}
 
 			return client, nil
 		},
-		func(obj interface{}) error {
+		Destroy: func(obj interface{}) error {
 			client, ok := obj.(*gorm.DB)
 			if !ok {
 				return nil
@@ -29,7 +45,10 @@ func NewMysqlClient(factory *factory.ObjectFactory, uri, env string, maxOpenConn
 
 			return client.Close()
 		},
-	)
+		Check: func(ctx context.Context, obj interface{}) error {
+			return obj.(*gorm.DB).DB().PingContext(ctx)
+		},
+	})
 	if err != nil {
 		return nil, err
 	}
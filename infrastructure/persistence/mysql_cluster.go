@@ -0,0 +1,260 @@
+package persistence
+
+import (
+	"context"
+	"ddd-demo/common/factory"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 只读副本的负载均衡策略
+const (
+	// BalancerRoundRobin 轮询
+	BalancerRoundRobin = "round_robin"
+	// BalancerLeastLatency 选择健康检查延迟最小的副本
+	BalancerLeastLatency = "least_latency"
+)
+
+// latencyWeight 计算延迟的指数加权移动平均时，新样本的权重
+const latencyWeight = 0.3
+
+// GormConnector 为读写操作提供 *gorm.DB
+type GormConnector interface {
+	// Reader 获取执行读操作的 *gorm.DB
+	Reader(ctx context.Context) *gorm.DB
+	// Writer 获取执行写操作的 *gorm.DB
+	Writer(ctx context.Context) *gorm.DB
+}
+
+// singleGorm 只有一个数据库的 GormConnector
+type singleGorm struct {
+	db *gorm.DB
+}
+
+// Reader 获取 ctx 中的事务或者 db
+func (s *singleGorm) Reader(ctx context.Context) *gorm.DB {
+	return GormFromContext(ctx, s.db)
+}
+
+// Writer 获取 ctx 中的事务或者 db
+func (s *singleGorm) Writer(ctx context.Context) *gorm.DB {
+	return GormFromContext(ctx, s.db)
+}
+
+// primaryKey 在 context 中标记读操作使用主库
+type primaryKey struct{}
+
+// writeTracker 记录 context 中最近一次写操作的时间（UnixNano）
+type writeTracker struct {
+	lastWrite int64
+}
+
+// writeTrackerKey 在 context 中保存 writeTracker 的 Key
+type writeTrackerKey struct{}
+
+// WithPrimary 返回的 context 中的读操作都使用主库
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// WithWriteTracking 返回的 context 会记录写操作，写操作之后 MysqlConfig.StickyWindow 内的读操作使用主库，
+// 以便读到刚刚写入的数据。通常在每个请求开始时调用
+func WithWriteTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeTrackerKey{}, &writeTracker{})
+}
+
+// mysqlReplica 只读副本
+type mysqlReplica struct {
+	uri string
+	// db 副本的连接，启动时无法连接的副本为 nil，健康检查时重新连接
+	db atomic.Value
+	// healthy 是否健康，1 表示健康，不健康的副本不会被选中
+	healthy int32
+	// failures 连续失败的健康检查次数
+	failures int32
+	// latency 健康检查延迟的指数加权移动平均（纳秒）
+	latency int64
+}
+
+// conn 获取副本的连接
+func (r *mysqlReplica) conn() *gorm.DB {
+	db, _ := r.db.Load().(*gorm.DB)
+	return db
+}
+
+// MysqlCluster MySQL 主库以及只读副本，读操作路由到健康的副本，写操作、事务中的读操作以及刚写入后的读操作使用主库。
+// 后台定时对副本做健康检查，连续失败达到阈值的副本被剔除，恢复后重新加入
+type MysqlCluster struct {
+	factory  *factory.ObjectFactory
+	config   *MysqlConfig
+	env      string
+	primary  *gorm.DB
+	replicas []*mysqlReplica
+	// next 轮询的计数
+	next uint32
+
+	// mu 保护 stop 以及 done，健康检查未开始时为 nil
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMysqlCluster 创建 MySQL 主库以及只读副本的客户端，无法连接的副本先被剔除，之后的健康检查会尝试重新连接
+func NewMysqlCluster(factory *factory.ObjectFactory, config *MysqlConfig, env string) (*MysqlCluster, error) {
+	primary, err := NewMysqlClient(factory, config.URI, env, config.MaxOpenConns, config.MaxIdleConns)
+	if err != nil {
+		return nil, err
+	}
+	c := &MysqlCluster{
+		factory: factory,
+		config:  config,
+		env:     env,
+		primary: primary,
+	}
+	for _, uri := range config.Replicas {
+		replica := &mysqlReplica{uri: uri}
+		if db, err := NewMysqlClient(factory, uri, env, config.MaxOpenConns, config.MaxIdleConns); err == nil {
+			replica.db.Store(db)
+			replica.healthy = 1
+		} else {
+			fmt.Println("connect mysql replica error:", err)
+		}
+		c.replicas = append(c.replicas, replica)
+	}
+	return c, nil
+}
+
+// Primary 获取主库
+func (c *MysqlCluster) Primary() *gorm.DB {
+	return c.primary
+}
+
+// Writer 获取执行写操作的 *gorm.DB，即 ctx 中的事务或者主库，并记录写操作的时间
+func (c *MysqlCluster) Writer(ctx context.Context) *gorm.DB {
+	if tracker, ok := ctx.Value(writeTrackerKey{}).(*writeTracker); ok {
+		atomic.StoreInt64(&tracker.lastWrite, time.Now().UnixNano())
+	}
+	return GormFromContext(ctx, c.primary)
+}
+
+// Reader 获取执行读操作的 *gorm.DB。ctx 中有事务时使用事务，
+// 通过 WithPrimary 指定、刚刚写入过或者没有健康的副本时使用主库，否则按负载均衡策略选择副本
+func (c *MysqlCluster) Reader(ctx context.Context) *gorm.DB {
+	if tx := GormFromContext(ctx, c.primary); tx != c.primary {
+		return tx
+	}
+	if forced, _ := ctx.Value(primaryKey{}).(bool); forced {
+		return c.primary
+	}
+	if tracker, ok := ctx.Value(writeTrackerKey{}).(*writeTracker); ok {
+		if lastWrite := atomic.LoadInt64(&tracker.lastWrite); lastWrite > 0 &&
+			time.Since(time.Unix(0, lastWrite)) < c.config.StickyWindow {
+			return c.primary
+		}
+	}
+	if replica := c.pick(); replica != nil {
+		return replica.conn()
+	}
+	return c.primary
+}
+
+// pick 按负载均衡策略选择健康的副本，没有健康的副本时返回 nil
+func (c *MysqlCluster) pick() *mysqlReplica {
+	healthy := make([]*mysqlReplica, 0, len(c.replicas))
+	for _, replica := range c.replicas {
+		if atomic.LoadInt32(&replica.healthy) == 1 && replica.conn() != nil {
+			healthy = append(healthy, replica)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	if c.config.Balancer == BalancerLeastLatency {
+		best := healthy[0]
+		for _, replica := range healthy[1:] {
+			if atomic.LoadInt64(&replica.latency) < atomic.LoadInt64(&best.latency) {
+				best = replica
+			}
+		}
+		return best
+	}
+	return healthy[int(atomic.AddUint32(&c.next, 1)-1)%len(healthy)]
+}
+
+// Ping 检查主库是否可以连接
+func (c *MysqlCluster) Ping(ctx context.Context) error {
+	return c.primary.DB().PingContext(ctx)
+}
+
+// StartHealthCheck 在后台定时对副本做健康检查，直到调用 StopHealthCheck，没有副本时不做检查
+func (c *MysqlCluster) StartHealthCheck() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil || len(c.replicas) == 0 || c.config.HealthCheckInterval <= 0 {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	c.stop, c.done = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(c.config.HealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				for _, replica := range c.replicas {
+					c.check(replica)
+				}
+			}
+		}
+	}()
+}
+
+// StopHealthCheck 停止健康检查，并等待后台协程退出
+func (c *MysqlCluster) StopHealthCheck() {
+	c.mu.Lock()
+	stop, done := c.stop, c.done
+	c.stop, c.done = nil, nil
+	c.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// check 对副本做一次健康检查，更新延迟以及健康状态。连接从工厂重新获取，
+// 因此连接被移除后可以重新建立
+func (c *MysqlCluster) check(replica *mysqlReplica) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.HealthCheckInterval)
+	defer cancel()
+	db, err := NewMysqlClientContext(ctx, c.factory, replica.uri, c.env, c.config.MaxOpenConns, c.config.MaxIdleConns)
+	if err != nil {
+		if atomic.AddInt32(&replica.failures, 1) >= int32(c.config.FailureThreshold) {
+			atomic.StoreInt32(&replica.healthy, 0)
+		}
+		return
+	}
+	replica.db.Store(db)
+
+	start := time.Now()
+	if err := db.DB().PingContext(ctx); err != nil {
+		if atomic.AddInt32(&replica.failures, 1) >= int32(c.config.FailureThreshold) &&
+			atomic.CompareAndSwapInt32(&replica.healthy, 1, 0) {
+			fmt.Println("mysql replica ejected:", err)
+		}
+		return
+	}
+	latency := int64(time.Since(start))
+	if old := atomic.LoadInt64(&replica.latency); old > 0 {
+		latency = int64(latencyWeight*float64(latency) + (1-latencyWeight)*float64(old))
+	}
+	atomic.StoreInt64(&replica.latency, latency)
+	atomic.StoreInt32(&replica.failures, 0)
+	atomic.StoreInt32(&replica.healthy, 1)
+}
@@ -1,6 +1,7 @@
 package persistence
 
 import (
+	"context"
 	"ddd-demo/common/factory"
 
 	"github.com/go-redis/redis/v8"
@@ -8,9 +9,14 @@ import (
 
 // NewRedisClient 创建 Redis 客户端
 func NewRedisClient(factory *factory.ObjectFactory, uri string) (*redis.Client, error) {
-	client, err := factory.Get(
-		uri,
-		func(uri string) (interface{}, error) {
+	return NewRedisClientContext(context.Background(), factory, uri)
+}
+
+// NewRedisClientContext 创建 Redis 客户端，连接在第一次使用时建立。
+// 工厂的健康检查通过 Ping 报告客户端是否可用，不会关闭已经注入的客户端
+func NewRedisClientContext(ctx context.Context, objectFactory *factory.ObjectFactory, uri string) (*redis.Client, error) {
+	client, err := objectFactory.GetContext(ctx, uri, &factory.Provider{
+		Create: func(_ context.Context, uri string) (interface{}, error) {
 			opts, err := redis.ParseURL(uri)
 			if err != nil {
 				return nil, err
@@ -18,7 +24,7 @@ func NewRedisClient(factory *factory.ObjectFactory, uri string) (*redis.Client,
 
 			return redis.NewClient(opts), nil
 		},
-		func(obj interface{}) error {
+		Destroy: func(obj interface{}) error {
 			client, ok := obj.(*redis.Client)
 			if !ok {
 				return nil
@@ -26,7 +32,10 @@ func NewRedisClient(factory *factory.ObjectFactory, uri string) (*redis.Client,
 
 			return client.Close()
 		},
-	)
+		Check: func(ctx context.Context, obj interface{}) error {
+			return obj.(*redis.Client).Ping(ctx).Err()
+		},
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,166 @@
+package persistence
+
+import (
+	"context"
+	"ddd-demo/domain/repository"
+	"fmt"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// gormTxKey 在 context 中保存 gorm 事务的 Key，不同的数据库使用不同的 Key
+type gormTxKey struct {
+	db *gorm.DB
+}
+
+// GormFromContext 获取 ctx 中 db 对应的事务，没有事务时返回 db 本身
+func GormFromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(gormTxKey{db: db}).(*gorm.DB); ok {
+		return tx
+	}
+	return db
+}
+
+// GormUnitOfWork 基于 gorm 事务的工作单元
+type GormUnitOfWork struct {
+	db *gorm.DB
+}
+
+// NewGormUnitOfWork 创建基于 gorm 事务的工作单元，db 需要与仓储使用的 *gorm.DB 相同，使用 MysqlCluster 时为 Primary()
+func NewGormUnitOfWork(db *gorm.DB) repository.UnitOfWork {
+	return &GormUnitOfWork{db: db}
+}
+
+// Do 在 gorm 事务中执行 fn
+func (u *GormUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(gormTxKey{db: u.db}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	tx := u.db.BeginTx(ctx, nil)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, gormTxKey{db: u.db}, tx)); err != nil {
+		if rollbackErr := tx.Rollback().Error; rollbackErr != nil {
+			return fmt.Errorf("%w (rollback error: %v)", err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// MongoDBUnitOfWork 基于 MongoDB 会话的多文档事务的工作单元，需要 MongoDB 部署为副本集或者分片集群
+type MongoDBUnitOfWork struct {
+	client *mongo.Client
+}
+
+// NewMongoDBUnitOfWork 创建基于 MongoDB 会话的工作单元，client 需要与仓储使用的 *mongo.Client 相同
+func NewMongoDBUnitOfWork(client *mongo.Client) repository.UnitOfWork {
+	return &MongoDBUnitOfWork{client: client}
+}
+
+// Do 在 MongoDB 事务中执行 fn。遇到 TransientTransactionError 等可以重试的错误时，fn 可能会被执行多次。
+// 发生 panic 时，会话结束时会中止事务
+func (u *MongoDBUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := u.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
+
+// memoryTxKey 在 context 中标记内存工作单元已经开始
+type memoryTxKey struct {
+	unitOfWork *MemoryUnitOfWork
+}
+
+// MemoryUnitOfWork 基于快照的内存工作单元，用于测试领域服务的事务语义。
+// 开始时保存仓储的快照，fn 返回错误或者发生 panic 时恢复快照。同一时间只有一个工作单元执行，但不隔离工作单元之外的读写
+type MemoryUnitOfWork struct {
+	mu           sync.Mutex
+	repositories []*MemoryRepository
+}
+
+// NewMemoryUnitOfWork 创建内存工作单元，repositories 为 NewMemoryRepository 创建的仓储
+func NewMemoryUnitOfWork(repositories ...repository.Repository) repository.UnitOfWork {
+	u := &MemoryUnitOfWork{}
+	for _, repo := range repositories {
+		if memoryRepository, ok := repo.(*MemoryRepository); ok {
+			u.repositories = append(u.repositories, memoryRepository)
+		}
+	}
+	return u
+}
+
+// memorySnapshot 内存仓储的快照
+type memorySnapshot struct {
+	keys  []string
+	items map[string][]byte
+}
+
+// Do 执行 fn，失败时恢复仓储的快照
+func (u *MemoryUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if ctx.Value(memoryTxKey{unitOfWork: u}) != nil {
+		return fn(ctx)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	snapshots := make([]*memorySnapshot, len(u.repositories))
+	for i, repo := range u.repositories {
+		snapshots[i] = repo.snapshot()
+	}
+	defer func() {
+		r := recover()
+		if err != nil || r != nil {
+			for i, repo := range u.repositories {
+				repo.restore(snapshots[i])
+			}
+		}
+		if r != nil {
+			panic(r)
+		}
+	}()
+	return fn(context.WithValue(ctx, memoryTxKey{unitOfWork: u}, true))
+}
+
+// snapshot 保存仓储的快照，实体是不可变的序列化结果，只需复制映射
+func (r *MemoryRepository) snapshot() *memorySnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	snapshot := &memorySnapshot{
+		keys:  append([]string{}, r.keys...),
+		items: make(map[string][]byte, len(r.items)),
+	}
+	for key, buf := range r.items {
+		snapshot.items[key] = buf
+	}
+	return snapshot
+}
+
+// restore 恢复仓储的快照
+func (r *MemoryRepository) restore(snapshot *memorySnapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = snapshot.keys
+	r.items = snapshot.items
+}
@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"ddd-demo/common/consts"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter 限流器
+type Limiter interface {
+	// Allow 请求 key 的 1 次配额
+	Allow(ctx context.Context, key string) (*Result, error)
+	// AllowN 请求 key 的 n 次配额，配额不足时不消耗。n 小于 1 或者大于配额上限（永远不会被允许）时
+	// 返回 consts.ErrRateLimitInvalid
+	AllowN(ctx context.Context, key string, n int64) (*Result, error)
+}
+
+// Result 限流结果，可以用于设置 X-RateLimit-* 响应头
+type Result struct {
+	// Allowed 是否允许
+	Allowed bool
+	// Limit 配额上限
+	Limit int64
+	// Remaining 剩余配额
+	Remaining int64
+	// ResetAt 配额恢复到上限的时间
+	ResetAt time.Time
+	// RetryAfter 不允许时，需要等待多久才有足够的配额
+	RetryAfter time.Duration
+}
+
+// TokenBucket 令牌桶的限制：每 Period 补充 Rate 个令牌，最多积累 Burst 个，允许短时间的突发请求。
+// Rate 需要大于 0，Period 至少为 1 毫秒
+type TokenBucket struct {
+	Rate   int64
+	Period time.Duration
+	// Burst 桶的容量，为 0 时等于 Rate
+	Burst int64
+}
+
+// validate 检查限制是否合法
+func (t TokenBucket) validate() error {
+	if t.Rate <= 0 || t.Period < time.Millisecond || t.Burst < 0 {
+		return fmt.Errorf("%w: token bucket rate %d per %s, burst %d", consts.ErrRateLimitInvalid, t.Rate, t.Period, t.Burst)
+	}
+	return nil
+}
+
+// capacity 桶的容量
+func (t TokenBucket) capacity() int64 {
+	if t.Burst > 0 {
+		return t.Burst
+	}
+	return t.Rate
+}
+
+// perMillisecond 每毫秒补充的令牌数
+func (t TokenBucket) perMillisecond() float64 {
+	return float64(t.Rate) / float64(t.Period.Milliseconds())
+}
+
+// SlidingWindow 滑动窗口的限制：任意 Window 时长内最多 Limit 次。Limit 需要大于 0，Window 至少为 1 毫秒
+type SlidingWindow struct {
+	Limit  int64
+	Window time.Duration
+}
+
+// validate 检查限制是否合法
+func (w SlidingWindow) validate() error {
+	if w.Limit <= 0 || w.Window < time.Millisecond {
+		return fmt.Errorf("%w: sliding window limit %d per %s", consts.ErrRateLimitInvalid, w.Limit, w.Window)
+	}
+	return nil
+}
+
+// validateN 检查请求的配额数量，至少为 1 并且不超过配额上限 limit
+func validateN(n, limit int64) error {
+	if n < 1 || n > limit {
+		return fmt.Errorf("%w: requested %d, limit %d", consts.ErrRateLimitInvalid, n, limit)
+	}
+	return nil
+}
+
+// fallbackLimiter primary 出错时使用 fallback 的限流器
+type fallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+}
+
+// NewFallbackLimiter 创建 primary（比如 Redis）出错时使用 fallback（比如进程内）的限流器。
+// 进程内限流只统计当前副本的请求，降级期间总的配额相当于副本数倍
+func NewFallbackLimiter(primary, fallback Limiter) Limiter {
+	return &fallbackLimiter{primary: primary, fallback: fallback}
+}
+
+// Allow 请求 1 次配额
+func (l *fallbackLimiter) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN 请求 n 次配额，primary 出错时使用 fallback。配额上限由 primary 与 fallback 检查
+func (l *fallbackLimiter) AllowN(ctx context.Context, key string, n int64) (*Result, error) {
+	if err := validateN(n, math.MaxInt64); err != nil {
+		return nil, err
+	}
+	result, err := l.primary.AllowN(ctx, key, n)
+	// 请求的配额超出上限不是 primary 的故障，不需要降级
+	if err != nil && ctx.Err() == nil && !errors.Is(err, consts.ErrRateLimitInvalid) {
+		return l.fallback.AllowN(ctx, key, n)
+	}
+	return result, err
+}
+
+// sweepInterval 进程内限流器清理过期 key 的间隔
+const sweepInterval = time.Minute
+
+// memoryStore 进程内限流器的状态，定期清理已经恢复到初始状态的 key
+type memoryStore struct {
+	mu        sync.Mutex
+	states    map[string]interface{}
+	expiresAt map[string]time.Time
+	sweptAt   time.Time
+}
+
+// newMemoryStore 创建进程内限流器的状态
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		states:    make(map[string]interface{}),
+		expiresAt: make(map[string]time.Time),
+		sweptAt:   time.Now(),
+	}
+}
+
+// update 在锁中获取并更新 key 的状态，fn 返回状态以及状态过期的时间
+func (s *memoryStore) update(key string, fn func(state interface{}, now time.Time) (interface{}, time.Time)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.sweptAt) >= sweepInterval {
+		for k, expiresAt := range s.expiresAt {
+			if now.After(expiresAt) {
+				delete(s.states, k)
+				delete(s.expiresAt, k)
+			}
+		}
+		s.sweptAt = now
+	}
+	var state interface{}
+	if expiresAt, found := s.expiresAt[key]; found && !now.After(expiresAt) {
+		state = s.states[key]
+	}
+	s.states[key], s.expiresAt[key] = fn(state, now)
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// slidingWindowScript 滑动窗口，有序集合记录窗口内每次请求的时间，使用 Redis 的时间避免副本之间的时钟偏差。
+// KEYS[1] 为有序集合，ARGV 为上限、窗口毫秒数、请求次数以及本次请求的唯一 ID，返回值与 tokenBucketScript 相同
+var slidingWindowScript = redis.NewScript(`
+redis.replicate_commands()
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local requested = tonumber(ARGV[3])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+local retry = 0
+if count + requested <= limit then
+	for i = 1, requested do
+		redis.call('ZADD', KEYS[1], now, ARGV[4] .. ':' .. i)
+	end
+	count = count + requested
+	allowed = 1
+else
+	retry = window
+	local index = count + requested - limit - 1
+	if requested <= limit then
+		local entry = redis.call('ZRANGE', KEYS[1], index, index, 'WITHSCORES')
+		retry = tonumber(entry[2]) + window - now
+	end
+end
+local reset = 0
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if newest[2] then
+	reset = tonumber(newest[2]) + window - now
+	redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+end
+return {allowed, limit - count, reset, retry}`)
+
+// redisSlidingWindow 基于 Redis 的滑动窗口限流器
+type redisSlidingWindow struct {
+	client redis.Scripter
+	name   string
+	limit  SlidingWindow
+}
+
+// NewRedisSlidingWindow 创建基于 Redis 的滑动窗口限流器，client 可以通过 persistence.NewRedisClient 获取，
+// name 用于区分不同的限制。每次请求占用有序集合中的一个成员，适合 Limit 不太大的限制。
+// 限制不合法时返回 consts.ErrRateLimitInvalid
+func NewRedisSlidingWindow(client *redis.Client, name string, limit SlidingWindow) (Limiter, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	return &redisSlidingWindow{client: client, name: name, limit: limit}, nil
+}
+
+// Allow 请求 1 次配额
+func (l *redisSlidingWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN 请求 n 次配额
+func (l *redisSlidingWindow) AllowN(ctx context.Context, key string, n int64) (*Result, error) {
+	if err := validateN(n, l.limit.Limit); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	values, err := slidingWindowScript.Run(ctx, l.client, []string{redisKey("sw", l.name, key)},
+		l.limit.Limit, l.limit.Window.Milliseconds(), n, hex.EncodeToString(buf)).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return scriptResult(values, l.limit.Limit), nil
+}
+
+// memorySlidingWindow 进程内的滑动窗口限流器
+type memorySlidingWindow struct {
+	store *memoryStore
+	limit SlidingWindow
+}
+
+// NewMemorySlidingWindow 创建进程内的滑动窗口限流器，限制不合法时返回 consts.ErrRateLimitInvalid
+func NewMemorySlidingWindow(limit SlidingWindow) (Limiter, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	return &memorySlidingWindow{store: newMemoryStore(), limit: limit}, nil
+}
+
+// Allow 请求 1 次配额
+func (l *memorySlidingWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN 请求 n 次配额
+func (l *memorySlidingWindow) AllowN(_ context.Context, key string, n int64) (*Result, error) {
+	if err := validateN(n, l.limit.Limit); err != nil {
+		return nil, err
+	}
+	result := &Result{Limit: l.limit.Limit}
+	l.store.update(key, func(state interface{}, now time.Time) (interface{}, time.Time) {
+		// 窗口内每次请求的时间，从早到晚排列
+		requests, _ := state.([]time.Time)
+		start := 0
+		for start < len(requests) && !requests[start].After(now.Add(-l.limit.Window)) {
+			start++
+		}
+		requests = requests[start:]
+		count := int64(len(requests))
+		if count+n <= l.limit.Limit {
+			for i := int64(0); i < n; i++ {
+				requests = append(requests, now)
+			}
+			count += n
+			result.Allowed = true
+		} else if n <= l.limit.Limit {
+			result.RetryAfter = requests[count+n-l.limit.Limit-1].Add(l.limit.Window).Sub(now)
+		} else {
+			result.RetryAfter = l.limit.Window
+		}
+		result.Remaining = l.limit.Limit - count
+		result.ResetAt = now
+		if len(requests) > 0 {
+			result.ResetAt = requests[len(requests)-1].Add(l.limit.Window)
+		}
+		return requests, result.ResetAt
+	})
+	return result, nil
+}
@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript 令牌桶，使用 Redis 的时间避免副本之间的时钟偏差。
+// KEYS[1] 为桶，ARGV 为容量、每毫秒补充的令牌数以及请求的令牌数，返回是否允许、剩余令牌、恢复满桶的毫秒数以及重试的毫秒数
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local requested = tonumber(ARGV[3])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= requested then
+	tokens = tokens - requested
+	allowed = 1
+else
+	retry = math.ceil((requested - tokens) / rate)
+end
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+return {allowed, math.floor(tokens), reset, retry}`)
+
+// redisTokenBucket 基于 Redis 的令牌桶限流器
+type redisTokenBucket struct {
+	client redis.Scripter
+	name   string
+	limit  TokenBucket
+}
+
+// NewRedisTokenBucket 创建基于 Redis 的令牌桶限流器，client 可以通过 persistence.NewRedisClient 获取，
+// name 用于区分不同的限制，比如 api:orders。限制不合法时返回 consts.ErrRateLimitInvalid
+func NewRedisTokenBucket(client *redis.Client, name string, limit TokenBucket) (Limiter, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	return &redisTokenBucket{client: client, name: name, limit: limit}, nil
+}
+
+// Allow 请求 1 个令牌
+func (l *redisTokenBucket) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN 请求 n 个令牌
+func (l *redisTokenBucket) AllowN(ctx context.Context, key string, n int64) (*Result, error) {
+	if err := validateN(n, l.limit.capacity()); err != nil {
+		return nil, err
+	}
+	values, err := tokenBucketScript.Run(ctx, l.client, []string{redisKey("tb", l.name, key)},
+		l.limit.capacity(), l.limit.perMillisecond(), n).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return scriptResult(values, l.limit.capacity()), nil
+}
+
+// memoryTokenBucket 进程内的令牌桶限流器
+type memoryTokenBucket struct {
+	store *memoryStore
+	limit TokenBucket
+}
+
+// tokenBucketState 进程内令牌桶的状态
+type tokenBucketState struct {
+	tokens float64
+	ts     time.Time
+}
+
+// NewMemoryTokenBucket 创建进程内的令牌桶限流器，限制不合法时返回 consts.ErrRateLimitInvalid
+func NewMemoryTokenBucket(limit TokenBucket) (Limiter, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	return &memoryTokenBucket{store: newMemoryStore(), limit: limit}, nil
+}
+
+// Allow 请求 1 个令牌
+func (l *memoryTokenBucket) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN 请求 n 个令牌
+func (l *memoryTokenBucket) AllowN(_ context.Context, key string, n int64) (*Result, error) {
+	if err := validateN(n, l.limit.capacity()); err != nil {
+		return nil, err
+	}
+	capacity := float64(l.limit.capacity())
+	rate := l.limit.perMillisecond()
+	result := &Result{Limit: l.limit.capacity()}
+	l.store.update(key, func(state interface{}, now time.Time) (interface{}, time.Time) {
+		bucket, ok := state.(*tokenBucketState)
+		if !ok {
+			bucket = &tokenBucketState{tokens: capacity, ts: now}
+		}
+		elapsed := float64(now.Sub(bucket.ts)) / float64(time.Millisecond)
+		bucket.tokens = math.Min(capacity, bucket.tokens+math.Max(0, elapsed)*rate)
+		bucket.ts = now
+		if bucket.tokens >= float64(n) {
+			bucket.tokens -= float64(n)
+			result.Allowed = true
+		} else {
+			result.RetryAfter = milliseconds(math.Ceil((float64(n) - bucket.tokens) / rate))
+		}
+		result.Remaining = int64(math.Floor(bucket.tokens))
+		result.ResetAt = now.Add(milliseconds(math.Ceil((capacity - bucket.tokens) / rate)))
+		return bucket, result.ResetAt
+	})
+	return result, nil
+}
+
+// milliseconds 将毫秒数转换为 time.Duration
+func milliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// redisKey 限流器在 Redis 中的 Key
+func redisKey(kind, name, key string) string {
+	return "ratelimit:" + kind + ":" + name + ":" + key
+}
+
+// scriptResult 将 Lua 脚本返回的是否允许、剩余配额、恢复的毫秒数以及重试的毫秒数转换为 Result
+func scriptResult(values []int64, limit int64) *Result {
+	now := time.Now()
+	return &Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Remaining:  values[1],
+		ResetAt:    now.Add(time.Duration(values[2]) * time.Millisecond),
+		RetryAfter: time.Duration(values[3]) * time.Millisecond,
+	}
+}
@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"ddd-demo/common/response"
+	"ddd-demo/infrastructure/config"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigController 配置相关的管理接口
+type ConfigController struct {
+	conf config.Configuration
+}
+
+// NewConfigController 创建 ConfigController
+func NewConfigController(conf config.Configuration) *ConfigController {
+	return &ConfigController{conf: conf}
+}
+
+// Dump 导出当前生效的配置，敏感配置已脱敏
+// @Summary 导出当前生效的配置
+// @Tags admin
+// @Produce json
+// @Success 200 {object} response.Response{data=[]config.Setting}
+// @Router /admin/config [get]
+func (cc *ConfigController) Dump(c *gin.Context) {
+	c.JSON(http.StatusOK, response.NewSuccessResponse(c, map[string]interface{}{
+		"profile":  cc.conf.Profile(),
+		"settings": config.Dump(cc.conf),
+	}))
+}
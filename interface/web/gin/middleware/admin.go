@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHeader 管理接口令牌的请求头
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminAuth 管理接口鉴权，token 返回空字符串时拒绝所有请求
+func AdminAuth(token func() string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := token()
+		if expected == "" {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		actual := c.Request.Header.Get(AdminTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
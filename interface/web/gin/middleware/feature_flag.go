@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"ddd-demo/infrastructure/featureflag"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 已认证的身份在 gin.Context 中的 Key，由鉴权中间件通过 c.Set 写入
+const (
+	// UserIDKey 用户 ID
+	UserIDKey = "userID"
+	// TenantIDKey 租户 ID
+	TenantIDKey = "tenantID"
+)
+
+// 用户与租户的请求头，只能在网关已经鉴权并覆盖这些请求头时使用
+const (
+	// UserIDHeader 用户 ID 的请求头
+	UserIDHeader = "X-User-ID"
+	// TenantIDHeader 租户 ID 的请求头
+	TenantIDHeader = "X-Tenant-ID"
+)
+
+// FeatureFlag 将功能开关的求值器以及当前请求的求值对象放入请求的 context，
+// 之后可以通过 featureflag.IsEnabled(c.Request.Context(), name) 求值。
+// target 为空时使用 ContextTarget，即鉴权中间件写入的身份。target 在求值时才调用，
+// 因此该中间件可以注册在鉴权中间件之前；求值只能在请求处理期间进行
+func FeatureFlag(evaluator featureflag.Evaluator, target func(c *gin.Context) *featureflag.Target) gin.HandlerFunc {
+	if target == nil {
+		target = ContextTarget
+	}
+	return func(c *gin.Context) {
+		ctx := featureflag.NewLazyContext(c.Request.Context(), evaluator, func() *featureflag.Target {
+			return target(c)
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// ContextTarget 从 gin.Context 的 UserIDKey 与 TenantIDKey 中读取求值对象，未认证时为空
+func ContextTarget(c *gin.Context) *featureflag.Target {
+	return &featureflag.Target{
+		UserID:   c.GetString(UserIDKey),
+		TenantID: c.GetString(TenantIDKey),
+	}
+}
+
+// HeaderTarget 从 X-User-ID 与 X-Tenant-ID 请求头中读取求值对象。请求头可以被客户端伪造，
+// 只能在受信任的网关已经鉴权并覆盖这些请求头时使用
+func HeaderTarget(c *gin.Context) *featureflag.Target {
+	return &featureflag.Target{
+		UserID:   c.GetHeader(UserIDHeader),
+		TenantID: c.GetHeader(TenantIDHeader),
+	}
+}
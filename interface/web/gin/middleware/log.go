@@ -13,10 +13,17 @@ import (
 
 // GinLog middleware for Gin
 func GinLog(logWithBody bool) gin.HandlerFunc {
+	return GinLogWithSwitch(func() bool {
+		return logWithBody
+	})
+}
+
+// GinLogWithSwitch 与 GinLog 相同，但是每个请求都通过 logWithBody 判断是否记录请求体，便于在运行时切换
+func GinLogWithSwitch(logWithBody func() bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
 		var requestBody string
-		if logWithBody {
+		if logWithBody() {
 			if requestBodyBytes, err := ioutil.ReadAll(c.Request.Body); err == nil {
 				requestBody = string(requestBodyBytes)
 				c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(requestBodyBytes))
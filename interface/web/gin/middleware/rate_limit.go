@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"ddd-demo/infrastructure/ratelimit"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 限流的响应头
+const (
+	// RateLimitLimitHeader 配额上限
+	RateLimitLimitHeader = "X-RateLimit-Limit"
+	// RateLimitRemainingHeader 剩余配额
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	// RateLimitResetHeader 配额恢复到上限的时间（Unix 秒）
+	RateLimitResetHeader = "X-RateLimit-Reset"
+	// RetryAfterHeader 被限流时需要等待的秒数
+	RetryAfterHeader = "Retry-After"
+)
+
+// RateLimit 按 key 限流并设置 X-RateLimit-* 响应头，超出配额时返回 429。
+// key 为空时使用 IPRateLimitKey，按用户限流可以使用 UserRateLimitKey；限流器出错时放行请求
+func RateLimit(limiter ratelimit.Limiter, key func(c *gin.Context) string) gin.HandlerFunc {
+	if key == nil {
+		key = IPRateLimitKey
+	}
+	return func(c *gin.Context) {
+		result, err := limiter.Allow(c.Request.Context(), key(c))
+		if err != nil {
+			fmt.Println("rate limit error:", err)
+			c.Next()
+			return
+		}
+		c.Header(RateLimitLimitHeader, strconv.FormatInt(result.Limit, 10))
+		c.Header(RateLimitRemainingHeader, strconv.FormatInt(result.Remaining, 10))
+		c.Header(RateLimitResetHeader, strconv.FormatInt(int64(math.Ceil(float64(result.ResetAt.UnixNano())/1e9)), 10))
+		if !result.Allowed {
+			c.Header(RetryAfterHeader, strconv.FormatInt(int64(math.Ceil(result.RetryAfter.Seconds())), 10))
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		c.Next()
+	}
+}
+
+// IPRateLimitKey 按客户端 IP 限流，只有来自受信任代理的请求才使用 X-Forwarded-For 中的地址，
+// 因此需要通过 gin.Engine.SetTrustedProxies 配置代理，否则客户端可以伪造请求头绕过限流
+func IPRateLimitKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// UserRateLimitKey 按鉴权中间件写入 UserIDKey 的用户限流，未认证的请求按客户端 IP 限流。
+// 不要使用客户端可以随意修改的请求头作为 key，否则每次换一个值即可绕过限流
+func UserRateLimitKey(c *gin.Context) string {
+	if userID := c.GetString(UserIDKey); userID != "" {
+		return "user:" + userID
+	}
+	return IPRateLimitKey(c)
+}
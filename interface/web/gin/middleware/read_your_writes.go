@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"ddd-demo/infrastructure/persistence"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadYourWrites 在请求的 context 中记录写操作，使用 persistence.MysqlCluster 时，
+// 同一请求写入之后的读操作使用主库，避免副本复制延迟导致读不到刚写入的数据
+func ReadYourWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(persistence.WithWriteTracking(c.Request.Context()))
+		c.Next()
+	}
+}
@@ -0,0 +1,260 @@
+package query
+
+import (
+	"ddd-demo/common/consts"
+	"ddd-demo/domain/repository"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 保留的查询参数，不能作为过滤字段
+const (
+	// ParamSort 排序，多个字段用逗号分隔，- 前缀表示降序，比如 sort=-createdAt,id
+	ParamSort = "sort"
+	// ParamPage 偏移分页的页码，从 1 开始
+	ParamPage = "page"
+	// ParamSize 每页的数量
+	ParamSize = "size"
+	// ParamCursor 游标分页的游标，出现该参数时使用游标分页，第一页传空值
+	ParamCursor = "cursor"
+)
+
+const (
+	// DefaultSize 默认的每页数量
+	DefaultSize = 20
+	// DefaultMaxSize 默认的每页最大数量
+	DefaultMaxSize = 100
+)
+
+// Field 允许过滤或者排序的字段
+type Field struct {
+	// Name 实体结构体的字段名
+	Name string
+	// Operators 允许的运算符，为空时只允许 repository.OpEq
+	Operators []repository.Operator
+	// Sortable 是否允许排序
+	Sortable bool
+	// Parse 将参数值转换为字段的类型，为空时使用字符串
+	Parse func(value string) (interface{}, error)
+}
+
+// allows 是否允许使用运算符
+func (f *Field) allows(operator repository.Operator) bool {
+	if len(f.Operators) == 0 {
+		return operator == repository.OpEq
+	}
+	for _, allowed := range f.Operators {
+		if allowed == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// parse 转换参数值
+func (f *Field) parse(value string) (interface{}, error) {
+	if f.Parse == nil {
+		return value, nil
+	}
+	return f.Parse(value)
+}
+
+// Parser 将 gin 的查询参数解析为 repository.Query，只有 Fields 中的参数可以用于过滤与排序。
+// 过滤参数的格式为 name=value 或者 name[op]=value，op 为 eq、ne、gt、gte、lt、lte、in、like，
+// in 的多个值用逗号分隔，多个过滤参数之间为 And。不在 Fields 中的普通参数会被忽略
+type Parser struct {
+	// Fields 参数名到字段的映射
+	Fields map[string]*Field
+	// DefaultSort 未指定排序时的排序条件
+	DefaultSort []repository.Order
+	// KeyField 唯一的字段（通常是 ID），游标分页时追加到排序条件的最后，保证排序稳定
+	KeyField string
+	// DefaultSize 默认的每页数量，为 0 时使用 DefaultSize
+	DefaultSize int
+	// MaxSize 每页的最大数量，超过时使用最大数量，为 0 时使用 DefaultMaxSize
+	MaxSize int
+}
+
+// Parse 解析请求的查询参数，参数不合法时返回 consts.ErrRepositoryInvalidQuery
+func (p *Parser) Parse(c *gin.Context) (*repository.Query, error) {
+	return p.ParseValues(c.Request.URL.Query())
+}
+
+// ParseValues 解析查询参数
+func (p *Parser) ParseValues(values url.Values) (*repository.Query, error) {
+	spec, err := p.parseSpec(values)
+	if err != nil {
+		return nil, err
+	}
+	query := &repository.Query{Spec: spec, Page: 1}
+	if query.Sort, err = p.parseSort(values.Get(ParamSort)); err != nil {
+		return nil, err
+	}
+	if query.Size, err = p.parseSize(values.Get(ParamSize)); err != nil {
+		return nil, err
+	}
+
+	if _, found := values[ParamCursor]; found {
+		query.Cursor = true
+		query.After = values.Get(ParamCursor)
+		if p.KeyField != "" && !sortsBy(query.Sort, p.KeyField) {
+			query.Sort = append(query.Sort, repository.Order{Field: p.KeyField})
+		}
+	} else if page := values.Get(ParamPage); page != "" {
+		if query.Page, err = strconv.Atoi(page); err != nil || query.Page < 1 {
+			return nil, fmt.Errorf("%w: %s must be a positive integer", consts.ErrRepositoryInvalidQuery, ParamPage)
+		}
+	}
+	return query, nil
+}
+
+// parseSpec 将过滤参数解析为查询条件，按参数名排序以便生成的条件稳定，没有过滤参数时返回 nil
+func (p *Parser) parseSpec(values url.Values) (repository.Specification, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var specs []repository.Specification
+	for _, key := range keys {
+		if key == ParamSort || key == ParamPage || key == ParamSize || key == ParamCursor {
+			continue
+		}
+		name, operator := key, repository.OpEq
+		bracketed := strings.HasSuffix(key, "]") && strings.Contains(key, "[")
+		if bracketed {
+			index := strings.Index(key, "[")
+			name, operator = key[:index], repository.Operator(key[index+1:len(key)-1])
+		}
+		field, found := p.Fields[name]
+		if !found {
+			if bracketed {
+				return nil, fmt.Errorf("%w: field %s is not allowed", consts.ErrRepositoryInvalidQuery, name)
+			}
+			continue
+		}
+		if !field.allows(operator) {
+			return nil, fmt.Errorf("%w: operator %s is not allowed on %s", consts.ErrRepositoryInvalidQuery, operator, name)
+		}
+		for _, raw := range values[key] {
+			spec, err := p.condition(name, field, operator, raw)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec)
+		}
+	}
+	switch len(specs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return specs[0], nil
+	}
+	return repository.And(specs...), nil
+}
+
+// condition 将一个过滤参数转换为查询条件
+func (p *Parser) condition(name string, field *Field, operator repository.Operator, raw string) (repository.Specification, error) {
+	if operator == repository.OpLike {
+		return repository.Like(field.Name, raw), nil
+	}
+	if operator == repository.OpIn {
+		var values []interface{}
+		for _, item := range strings.Split(raw, ",") {
+			value, err := field.parse(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", consts.ErrRepositoryInvalidQuery, name, err)
+			}
+			values = append(values, value)
+		}
+		return &repository.Condition{Field: field.Name, Operator: repository.OpIn, Value: values}, nil
+	}
+	value, err := field.parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", consts.ErrRepositoryInvalidQuery, name, err)
+	}
+	switch operator {
+	case repository.OpEq, repository.OpNe, repository.OpGt, repository.OpGte, repository.OpLt, repository.OpLte:
+		return &repository.Condition{Field: field.Name, Operator: operator, Value: value}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown operator %s", consts.ErrRepositoryInvalidQuery, operator)
+}
+
+// parseSort 解析排序参数，为空时使用 DefaultSort
+func (p *Parser) parseSort(raw string) ([]repository.Order, error) {
+	if raw == "" {
+		return append([]repository.Order(nil), p.DefaultSort...), nil
+	}
+	var orders []repository.Order
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		desc := strings.HasPrefix(item, "-")
+		name := strings.TrimPrefix(item, "-")
+		field, found := p.Fields[name]
+		if !found || !field.Sortable {
+			return nil, fmt.Errorf("%w: sorting by %s is not allowed", consts.ErrRepositoryInvalidQuery, name)
+		}
+		if !sortsBy(orders, field.Name) {
+			orders = append(orders, repository.Order{Field: field.Name, Desc: desc})
+		}
+	}
+	return orders, nil
+}
+
+// parseSize 解析每页数量，超过最大数量时使用最大数量
+func (p *Parser) parseSize(raw string) (int, error) {
+	size, maxSize := p.DefaultSize, p.MaxSize
+	if size <= 0 {
+		size = DefaultSize
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if raw != "" {
+		var err error
+		if size, err = strconv.Atoi(raw); err != nil || size < 1 {
+			return 0, fmt.Errorf("%w: %s must be a positive integer", consts.ErrRepositoryInvalidQuery, ParamSize)
+		}
+	}
+	if size > maxSize {
+		size = maxSize
+	}
+	return size, nil
+}
+
+// sortsBy 排序条件中是否包含字段
+func sortsBy(orders []repository.Order, field string) bool {
+	for _, order := range orders {
+		if order.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Int 将参数值转换为 int64，用于 Field.Parse
+func Int(value string) (interface{}, error) {
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Float 将参数值转换为 float64，用于 Field.Parse
+func Float(value string) (interface{}, error) {
+	return strconv.ParseFloat(value, 64)
+}
+
+// Bool 将参数值转换为 bool，用于 Field.Parse
+func Bool(value string) (interface{}, error) {
+	return strconv.ParseBool(value)
+}
+
+// Time 将 RFC3339 格式的参数值转换为 time.Time，用于 Field.Parse
+func Time(value string) (interface{}, error) {
+	return time.Parse(time.RFC3339, value)
+}
@@ -1,40 +1,105 @@
 package router
 
 import (
+	"context"
+	"ddd-demo/infrastructure/health"
 	"ddd-demo/interface/web/gin/middleware"
+	"fmt"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 )
 
 type GinRouter struct {
-	appName     string
-	logWithBody bool
+	appName string
+	// logWithBody 是否记录请求体，1 表示记录，可在运行时修改
+	logWithBody int32
+	// trustedProxies 受信任的反向代理，只有来自这些地址的请求才会读取 X-Forwarded-For 等请求头获取客户端 IP
+	trustedProxies []string
+	// healthRegistry 健康检查的注册表，任意一个检查失败时对应的健康检查接口返回 503
+	healthRegistry *health.Registry
 }
 
 var (
 	Router *gin.Engine
 	ApiV1  *gin.RouterGroup
+	// Admin 管理接口
+	Admin *gin.RouterGroup
 )
 
-func NewGinRouter(appName string, logWithBody bool) *GinRouter {
-	return &GinRouter{
-		appName:     appName,
-		logWithBody: logWithBody,
+// NewGinRouter 创建 GinRouter，trustedProxies 为空时不信任任何代理，客户端 IP 取连接的对端地址；
+// healthRegistry 为空时使用默认配置的注册表
+func NewGinRouter(appName string, logWithBody bool, trustedProxies []string, healthRegistry *health.Registry) *GinRouter {
+	if healthRegistry == nil {
+		healthRegistry = health.NewRegistry(health.DefaultTimeout, health.DefaultCacheTTL)
 	}
+	r := &GinRouter{appName: appName, trustedProxies: trustedProxies, healthRegistry: healthRegistry}
+	r.SetLogWithBody(logWithBody)
+	return r
+}
+
+// SetLogWithBody 设置是否记录请求体
+func (r *GinRouter) SetLogWithBody(logWithBody bool) {
+	var value int32
+	if logWithBody {
+		value = 1
+	}
+	atomic.StoreInt32(&r.logWithBody, value)
+}
+
+// LogWithBody 是否记录请求体
+func (r *GinRouter) LogWithBody() bool {
+	return atomic.LoadInt32(&r.logWithBody) == 1
 }
 
 func (r *GinRouter) Start() *gin.Engine {
 	Router = gin.New()
+	// gin 默认信任所有代理，客户端可以伪造 X-Forwarded-For 绕过按 IP 的限流，这里只信任配置的代理
+	if err := Router.SetTrustedProxies(r.trustedProxies); err != nil {
+		fmt.Println("invalid trusted proxies, trusting none:", err)
+		_ = Router.SetTrustedProxies(nil)
+	}
 	// 添加 CORS 中间件
 	Router.Use(middleware.CORS())
 	// 添加 GinLog
-	Router.Use(middleware.GinLog(r.logWithBody))
-	// 添加健康检查接口
-	Router.GET("/health", func(c *gin.Context) {
-		c.Status(http.StatusOK)
-	})
+	Router.Use(middleware.GinLogWithSwitch(r.LogWithBody))
+	// 添加健康检查接口，/health 与 /health/live 相同，保持原来存活检查的语义
+	Router.GET("/health", r.liveness)
+	Router.GET("/health/live", r.liveness)
+	Router.GET("/health/ready", r.readiness)
 	ApiV1 = Router.Group("/api/v1")
+	Admin = Router.Group("/admin")
 
 	return Router
 }
+
+// AddReadinessCheck 添加就绪检查，比如缓存预热是否完成，结果不缓存
+func (r *GinRouter) AddReadinessCheck(name string, check func() error) {
+	r.healthRegistry.Register(&health.Check{
+		Name: name,
+		Probe: func(context.Context) error {
+			return check()
+		},
+		CacheTTL: -1,
+	})
+}
+
+// liveness 存活检查，返回各检查的状态以及延迟，任意一个失败时返回 503
+func (r *GinRouter) liveness(c *gin.Context) {
+	writeReport(c, r.healthRegistry.Liveness(c.Request.Context()))
+}
+
+// readiness 就绪检查，返回各依赖的状态以及延迟，任意一个不可用时返回 503
+func (r *GinRouter) readiness(c *gin.Context) {
+	writeReport(c, r.healthRegistry.Readiness(c.Request.Context()))
+}
+
+// writeReport 输出健康检查报告
+func writeReport(c *gin.Context, report *health.Report) {
+	status := http.StatusOK
+	if !report.Up() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -2,8 +2,18 @@ package main
 
 import (
 	"context"
+	"ddd-demo/common/factory"
+	"ddd-demo/infrastructure/cache"
 	"ddd-demo/infrastructure/config"
+	"ddd-demo/infrastructure/featureflag"
+	"ddd-demo/infrastructure/health"
+	infrahttp "ddd-demo/infrastructure/http"
+	"ddd-demo/infrastructure/migration"
+	"ddd-demo/infrastructure/singleflight"
+	"ddd-demo/interface/web/gin/controller/admin"
+	"ddd-demo/interface/web/gin/middleware"
 	"ddd-demo/interface/web/gin/router"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -12,17 +22,86 @@ import (
 	"time"
 
 	"go.uber.org/fx"
+	xsingleflight "golang.org/x/sync/singleflight"
 )
 
 var ginRouter *router.GinRouter
 
 // PreStart 启动 HTTP Server 前的准备工作
-func PreStart(conf config.Configuration) {
+func PreStart(conf config.Configuration, evaluator featureflag.Evaluator, healthRegistry *health.Registry) {
 	ginRouter = router.NewGinRouter(
 		conf.GetString("server.appName"),
 		conf.GetBool("server.logWithBody"),
+		conf.GetStringSlice("server.trustedProxies"),
+		healthRegistry,
 	)
 	ginRouter.Start()
+	// 业务接口可以通过 featureflag.IsEnabled(c.Request.Context(), name) 判断功能开关是否开启
+	router.ApiV1.Use(middleware.FeatureFlag(evaluator, nil))
+	// 使用 MySQL 只读副本时，同一请求写入之后的读操作使用主库
+	router.ApiV1.Use(middleware.ReadYourWrites())
+	// 启动时输出生效的配置，敏感配置已脱敏
+	if settings, err := json.Marshal(config.Dump(conf)); err == nil {
+		fmt.Printf("effective config (profile %q): %s\n", conf.Profile(), settings)
+	}
+	// 添加管理接口，需要通过 X-Admin-Token 请求头提供 server.admin.token，未配置令牌时不注册
+	if conf.GetBool("server.admin.enabled") && conf.GetString("server.admin.token") == "" {
+		fmt.Println("server.admin.token is empty, admin endpoints are disabled")
+	} else if conf.GetBool("server.admin.enabled") {
+		router.Admin.Use(middleware.AdminAuth(func() string {
+			return conf.GetString("server.admin.token")
+		}))
+		router.Admin.GET("/config", admin.NewConfigController(conf).Dump)
+	}
+	// 配置文件变化后，在运行时切换是否记录请求体
+	conf.Watch("server.logWithBody", func(_, _ interface{}) {
+		ginRouter.SetLogWithBody(conf.GetBool("server.logWithBody"))
+	})
+}
+
+// CacheWarmerParams 创建缓存预热器的参数，业务模块可以向 cache_warmup_tasks 组中提供预热任务
+type CacheWarmerParams struct {
+	fx.In
+
+	Conf       config.Configuration
+	LocalCache cache.LocalCache
+	Sfg        *xsingleflight.Group
+	Tasks      []*cache.WarmupTask `group:"cache_warmup_tasks"`
+}
+
+// NewCacheWarmer 创建缓存预热器
+func NewCacheWarmer(p CacheWarmerParams) *cache.Warmer {
+	warmer := cache.NewWarmer(p.LocalCache, p.Sfg, p.Conf.GetInt("cache.warmup.concurrency"))
+	warmer.Register(p.Tasks...)
+	return warmer
+}
+
+// WarmupCache 在 HTTP Server 启动前预热缓存，超出启动时限的部分在后台继续执行，完成前就绪检查接口返回 503
+func WarmupCache(lc fx.Lifecycle, warmer *cache.Warmer) {
+	ginRouter.AddReadinessCheck("cache_warmup", warmer.Check)
+	ctx, cancel := context.WithCancel(context.Background())
+	lc.Append(fx.Hook{
+		OnStart: func(startCtx context.Context) error {
+			done := make(chan error, 1)
+			go func() {
+				done <- warmer.Warmup(ctx)
+			}()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					fmt.Println(err)
+				}
+			case <-startCtx.Done():
+				fmt.Println("cache warmup continues in background")
+			}
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
 }
 
 // ServeHTTP 启动以及关闭 HTTP Server
@@ -83,12 +162,41 @@ func ServeHTTP(lc fx.Lifecycle, conf config.Configuration) {
 // @BasePath /api
 // @Schemes http https
 func main() {
+	// 执行子命令，比如 encrypt 用于加密配置值，migrate 用于执行数据库迁移
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "encrypt":
+			if err := config.RunEncryptCommand(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		case "migrate":
+			if err := migration.RunCommand(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	app := fx.New(
+		// 按需引入基础设施模块，比如 persistence.MysqlModule、persistence.MongoDBModule、persistence.RedisModule、
+		// mq.Module、es.Module、outbox.Module，它们从配置中读取连接信息，启动时检查连接，停止时通过 factory.Module 释放资源，
+		// 并向 health.Module 注册就绪检查
+		factory.Module,
+		health.Module,
+		infrahttp.Module,
 		fx.Provide(
 			config.NewYamlConfiguration,
+			cache.NewBigCacheLocalCache,
+			singleflight.NewSingleFlightGroup,
+			NewCacheWarmer,
+			featureflag.NewConfigEvaluator,
 		),
 		fx.Invoke(
 			PreStart,
+			WarmupCache,
 			ServeHTTP,
 		),
 	)
@@ -96,7 +204,7 @@ func main() {
 		panic(err)
 	}
 
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 